@@ -0,0 +1,97 @@
+package canary
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/appleboy/loadbalancer-algorithms/hashkey"
+	"github.com/appleboy/loadbalancer-algorithms/internal/sampler"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+// ErrInvalidPercent is returned when the canary percentage is not in the range [0, 100].
+var ErrInvalidPercent = errors.New("canary: percent must be in the range [0, 100]")
+
+// Canary splits the traffic between a stable and a canary pool, each being
+// its own load balancer. The canary percentage is exact: out of every 100
+// requests, percent go to the canary pool. It can be changed at runtime.
+//
+// With a sticky key, the split is done per key instead of per request, so a
+// user always lands on the same pool for a given percentage. The requests
+// without a key are split per request.
+type Canary struct {
+	stable proxy.Balancer
+	canary proxy.Balancer
+	key    hashkey.KeyExtractor
+
+	// sampler selects the requests sent to the canary pool.
+	sampler sampler.Sampler
+}
+
+// New creates a traffic splitter sending percent of the requests to the canary pool.
+func New(stable, canary proxy.Balancer, percent float64, opts ...Opts) (*Canary, error) {
+	c := &Canary{
+		stable: stable,
+		canary: canary,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if err := c.SetPercent(percent); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// SetPercent changes the percentage of the requests sent to the canary pool.
+func (c *Canary) SetPercent(percent float64) error {
+	if percent < 0 || percent > 100 || math.IsNaN(percent) {
+		return ErrInvalidPercent
+	}
+
+	c.sampler.SetRate(percent / 100)
+	return nil
+}
+
+// Percent returns the percentage of the requests sent to the canary pool.
+func (c *Canary) Percent() float64 {
+	return c.sampler.Rate() * 100
+}
+
+// NextServer returns the next server of the pool selected for the request.
+// It falls back to the stable pool if the canary pool has no server.
+func (c *Canary) NextServer(r *http.Request) *proxy.Proxy {
+	if c.isCanary(r) {
+		if server := c.canary.NextServer(); server != nil {
+			return server
+		}
+	}
+	return c.stable.NextServer()
+}
+
+// ServeHTTP forwards the request to the server selected by NextServer.
+// It responds with 503 Service Unavailable if no server is available.
+func (c *Canary) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := c.NextServer(r)
+	if server == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// isCanary reports whether the request goes to the canary pool.
+func (c *Canary) isCanary(r *http.Request) bool {
+	if c.key != nil {
+		if key, ok := c.key(r); ok {
+			return c.sampler.SampleHash(hashkey.Sum64(key))
+		}
+	}
+
+	return c.sampler.Sample()
+}
@@ -0,0 +1,124 @@
+package canary
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/hashkey"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+func newPools(t *testing.T) (roundrobin.RoundRobin, roundrobin.RoundRobin) {
+	t.Helper()
+	stable, _ := roundrobin.New(
+		proxy.NewProxy("s1", &url.URL{Host: "192.168.1.10"}),
+		proxy.NewProxy("s2", &url.URL{Host: "192.168.1.11"}),
+	)
+	canary, _ := roundrobin.New(
+		proxy.NewProxy("c1", &url.URL{Host: "192.168.2.10"}),
+	)
+	t.Cleanup(stable.RemoveAll)
+	t.Cleanup(canary.RemoveAll)
+	return stable, canary
+}
+
+func TestNew(t *testing.T) {
+	stable, canary := newPools(t)
+	for _, percent := range []float64{-1, 101} {
+		if _, err := New(stable, canary, percent); !errors.Is(err, ErrInvalidPercent) {
+			t.Errorf("New(%v) error = %v, want %v", percent, err, ErrInvalidPercent)
+		}
+	}
+}
+
+func TestCanary_NextServer(t *testing.T) {
+	stable, canary := newPools(t)
+	c, err := New(stable, canary, 5)
+	if err != nil {
+		t.Fatalf("Failed to create canary: %v", err)
+	}
+
+	count := func(requests int) int {
+		n := 0
+		for i := 0; i < requests; i++ {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			if c.NextServer(req).GetName() == "c1" {
+				n++
+			}
+		}
+		return n
+	}
+
+	if n := count(1000); n != 50 {
+		t.Fatalf("Expected exactly 50/1000 canary requests, but got %d", n)
+	}
+
+	if err := c.SetPercent(25.5); err != nil {
+		t.Fatalf("Failed to set percent: %v", err)
+	}
+	if c.Percent() != 25.5 {
+		t.Fatalf("Percent() = %v, want 25.5", c.Percent())
+	}
+	if n := count(1000); n != 255 {
+		t.Fatalf("Expected exactly 255/1000 canary requests, but got %d", n)
+	}
+
+	_ = c.SetPercent(0)
+	if n := count(100); n != 0 {
+		t.Fatalf("Expected no canary requests, but got %d", n)
+	}
+	_ = c.SetPercent(100)
+	if n := count(100); n != 100 {
+		t.Fatalf("Expected only canary requests, but got %d", n)
+	}
+}
+
+func TestCanary_StickyKey(t *testing.T) {
+	stable, canary := newPools(t)
+	c, err := New(stable, canary, 50, WithStickyKey(hashkey.Header("X-User")))
+	if err != nil {
+		t.Fatalf("Failed to create canary: %v", err)
+	}
+
+	canaryUsers := 0
+	for i := 0; i < 100; i++ {
+		user := fmt.Sprintf("user-%d", i)
+		first := ""
+		for j := 0; j < 3; j++ {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header.Set("X-User", user)
+			pool := "stable"
+			if c.NextServer(req).GetName() == "c1" {
+				pool = "canary"
+			}
+			if first == "" {
+				first = pool
+			} else if pool != first {
+				t.Fatalf("User %s flipped from %s to %s", user, first, pool)
+			}
+		}
+		if first == "canary" {
+			canaryUsers++
+		}
+	}
+
+	if canaryUsers == 0 || canaryUsers == 100 {
+		t.Fatalf("Expected users to be split between the pools, but got %d canary users", canaryUsers)
+	}
+}
+
+func TestCanary_Fallback(t *testing.T) {
+	stable, _ := newPools(t)
+	empty, _ := roundrobin.New()
+	c, _ := New(stable, empty, 100)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if name := c.NextServer(req).GetName(); name != "s1" {
+		t.Fatalf("Expected fallback to the stable pool, but got %s", name)
+	}
+}
@@ -0,0 +1,14 @@
+package canary
+
+import "github.com/appleboy/loadbalancer-algorithms/hashkey"
+
+// Opts configures a Canary.
+type Opts func(*Canary)
+
+// WithStickyKey splits the traffic per key extracted from the request,
+// such as a user id, so that a user does not flip between the pools.
+func WithStickyKey(key hashkey.KeyExtractor) Opts {
+	return func(c *Canary) {
+		c.key = key
+	}
+}
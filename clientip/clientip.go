@@ -0,0 +1,213 @@
+package clientip
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/appleboy/loadbalancer-algorithms/hashkey"
+)
+
+var (
+	// ErrInvalidCIDR is returned when a trusted proxy CIDR cannot be parsed.
+	ErrInvalidCIDR = errors.New("clientip: invalid trusted proxy CIDR")
+	// ErrInvalidPrefix is returned when a prefix length is out of range.
+	ErrInvalidPrefix = errors.New("clientip: invalid prefix length")
+	// ErrInvalidHeader is returned when the forwarding header is not a defined Header.
+	ErrInvalidHeader = errors.New("clientip: invalid forwarding header")
+)
+
+// Header is a forwarding header carrying the client address.
+type Header int
+
+const (
+	// XForwardedFor is the X-Forwarded-For header, a list of addresses.
+	XForwardedFor Header = iota
+	// Forwarded is the RFC 7239 Forwarded header, using its "for" parameters.
+	Forwarded
+	// XRealIP is the X-Real-IP header, a single address.
+	XRealIP
+)
+
+// Resolver resolves the IP address of the client of a request.
+//
+// The forwarding header is honored only when the request comes from a trusted
+// proxy. In that case the header chain is walked from the nearest hop to the
+// farthest, skipping trusted proxies, and the first untrusted address is the
+// client. Only the header set by the trusted proxies is used, X-Forwarded-For
+// by default: the other headers are passed through unchanged by the proxies
+// and can be set by the client.
+//
+// The resolved address can be masked to a prefix, for example to hash all
+// addresses of an IPv6 /64 network to the same backend.
+type Resolver struct {
+	trustedCIDRs []string
+	trusted      []*net.IPNet
+	header       Header
+	ipv4Prefix   int
+	ipv6Prefix   int
+}
+
+// New creates a Resolver. Without trusted proxies, the forwarding headers
+// are ignored and the address of the connection is used.
+func New(opts ...Opts) (*Resolver, error) {
+	r := &Resolver{
+		ipv4Prefix: 32,
+		ipv6Prefix: 128,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	if r.ipv4Prefix < 0 || r.ipv4Prefix > 32 || r.ipv6Prefix < 0 || r.ipv6Prefix > 128 {
+		return nil, ErrInvalidPrefix
+	}
+
+	if r.header < XForwardedFor || r.header > XRealIP {
+		return nil, ErrInvalidHeader
+	}
+
+	for _, cidr := range r.trustedCIDRs {
+		n, err := parseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		r.trusted = append(r.trusted, n)
+	}
+
+	return r, nil
+}
+
+// ClientIP returns the masked IP address of the client,
+// or nil if the address of the connection cannot be parsed.
+func (r *Resolver) ClientIP(req *http.Request) net.IP {
+	ip := r.resolve(req)
+	if ip == nil {
+		return nil
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(r.ipv4Prefix, 32))
+	}
+	return ip.Mask(net.CIDRMask(r.ipv6Prefix, 128))
+}
+
+// KeyExtractor returns a hashkey.KeyExtractor that extracts the client IP,
+// for example to balance by IP hash with keyhash.New.
+func (r *Resolver) KeyExtractor() hashkey.KeyExtractor {
+	return func(req *http.Request) (string, bool) {
+		ip := r.ClientIP(req)
+		if ip == nil {
+			return "", false
+		}
+		return ip.String(), true
+	}
+}
+
+// resolve returns the unmasked IP address of the client.
+func (r *Resolver) resolve(req *http.Request) net.IP {
+	remote := parseIP(req.RemoteAddr)
+	if remote == nil || !r.isTrusted(remote) {
+		return remote
+	}
+
+	var hops []string
+	switch r.header {
+	case Forwarded:
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	case XForwardedFor:
+		hops = splitList(req.Header.Values("X-Forwarded-For"))
+	case XRealIP:
+		if v := strings.TrimSpace(req.Header.Get("X-Real-IP")); v != "" {
+			hops = []string{v}
+		}
+	}
+
+	// Walk from the nearest hop, the last one, to the farthest.
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(hops[i])
+		if ip == nil {
+			// The chain cannot be followed past an unknown or obfuscated hop.
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+
+	return client
+}
+
+// isTrusted reports whether ip belongs to a trusted proxy.
+func (r *Resolver) isTrusted(ip net.IP) bool {
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDR parses a CIDR, or a single IP address as a host network.
+func parseCIDR(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCIDR, s)
+	}
+	return n, nil
+}
+
+// parseIP parses an IP address with an optional port, such as
+// "192.0.2.1", "192.0.2.1:80", "2001:db8::1" or "[2001:db8::1]:80".
+func parseIP(s string) net.IP {
+	s = strings.TrimSpace(s)
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	return net.ParseIP(s)
+}
+
+// splitList splits comma separated header values.
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(item))
+		}
+	}
+	return list
+}
+
+// forwardedFor returns the "for" parameters of RFC 7239 Forwarded header values.
+func forwardedFor(values []string) []string {
+	var hops []string
+	for _, element := range splitList(values) {
+		node := ""
+		for _, pair := range strings.Split(element, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(key, "for") {
+				node = strings.Trim(value, `"`)
+			}
+		}
+		hops = append(hops, node)
+	}
+	return hops
+}
@@ -0,0 +1,134 @@
+package clientip
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "default", err: nil},
+		{name: "valid proxies", opts: []Opts{WithTrustedProxies("10.0.0.0/8", "192.0.2.1", "2001:db8::/32")}, err: nil},
+		{name: "invalid cidr", opts: []Opts{WithTrustedProxies("10.0.0.0/40")}, err: ErrInvalidCIDR},
+		{name: "invalid ip", opts: []Opts{WithTrustedProxies("invalid")}, err: ErrInvalidCIDR},
+		{name: "invalid ipv4 prefix", opts: []Opts{WithIPv4Prefix(33)}, err: ErrInvalidPrefix},
+		{name: "invalid ipv6 prefix", opts: []Opts{WithIPv6Prefix(-1)}, err: ErrInvalidPrefix},
+		{name: "invalid header", opts: []Opts{WithHeader(Header(10))}, err: ErrInvalidHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResolver_ClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     Header
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "untrusted remote ignores headers",
+			remoteAddr: "203.0.113.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.1",
+		},
+		{
+			name:       "trusted remote without headers",
+			remoteAddr: "10.0.0.1:1234",
+			want:       "10.0.0.1",
+		},
+		{
+			name:       "x-forwarded-for skips trusted hops",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.2, 198.51.100.1, 10.0.0.2"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "x-forwarded-for with only trusted hops",
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"},
+			want:       "10.0.0.3",
+		},
+		{
+			name:       "x-real-ip",
+			header:     XRealIP,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "untrusted forwarded ignored",
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       "for=6.6.6.6",
+				"X-Forwarded-For": "198.51.100.1",
+			},
+			want: "198.51.100.1",
+		},
+		{
+			name:       "untrusted x-forwarded-for ignored",
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers: map[string]string{
+				"Forwarded":       `for=198.51.100.3;proto=https, for="10.0.0.2:8080"`,
+				"X-Forwarded-For": "6.6.6.6",
+			},
+			want: "198.51.100.3",
+		},
+		{
+			name:       "forwarded with unknown hop",
+			header:     Forwarded,
+			remoteAddr: "10.0.0.1:1234",
+			headers:    map[string]string{"Forwarded": "for=unknown, for=10.0.0.2"},
+			want:       "10.0.0.2",
+		},
+		{
+			name:       "ipv6 masked to prefix",
+			header:     Forwarded,
+			remoteAddr: "[2001:db8:ffff::1]:1234",
+			headers:    map[string]string{"Forwarded": `for="[2001:db8:cafe:1:2:3:4:5]:4711"`},
+			want:       "2001:db8:cafe:1::",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(
+				WithTrustedProxies("10.0.0.0/8", "2001:db8:ffff::/48"),
+				WithIPv6Prefix(64),
+				WithHeader(tt.header),
+			)
+			if err != nil {
+				t.Fatalf("Failed to create resolver: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if got := r.ClientIP(req).String(); got != tt.want {
+				t.Errorf("ClientIP() = %s, want %s", got, tt.want)
+			}
+
+			key, ok := r.KeyExtractor()(req)
+			if !ok || key != tt.want {
+				t.Errorf("KeyExtractor() = (%s, %v), want (%s, true)", key, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package clientip
+
+// Opts configures a Resolver.
+type Opts func(*Resolver)
+
+// WithTrustedProxies sets the CIDRs, or single IP addresses,
+// of the proxies whose forwarding headers are trusted.
+func WithTrustedProxies(cidrs ...string) Opts {
+	return func(r *Resolver) {
+		r.trustedCIDRs = append(r.trustedCIDRs, cidrs...)
+	}
+}
+
+// WithIPv4Prefix masks IPv4 client addresses to the prefix length. Defaults to 32.
+func WithIPv4Prefix(bits int) Opts {
+	return func(r *Resolver) {
+		r.ipv4Prefix = bits
+	}
+}
+
+// WithIPv6Prefix masks IPv6 client addresses to the prefix length,
+// for example 64 to treat a whole subnet as one client. Defaults to 128.
+func WithIPv6Prefix(bits int) Opts {
+	return func(r *Resolver) {
+		r.ipv6Prefix = bits
+	}
+}
+
+// WithHeader sets the forwarding header set by the trusted proxies.
+// The other forwarding headers are ignored. Defaults to XForwardedFor.
+func WithHeader(header Header) Opts {
+	return func(r *Resolver) {
+		r.header = header
+	}
+}
@@ -0,0 +1,95 @@
+package hashkey
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// KeyExtractor returns the routing key of the request used by key based
+// load balancing, such as keyhash.KeyHash and canary.WithStickyKey, and
+// false if the request does not carry such a key.
+type KeyExtractor func(r *http.Request) (string, bool)
+
+// Header extracts the key from the first value of the request header.
+func Header(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		v := r.Header.Get(name)
+		return v, v != ""
+	}
+}
+
+// Query extracts the key from the first value of the query parameter.
+func Query(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		v := r.URL.Query().Get(name)
+		return v, v != ""
+	}
+}
+
+// Cookie extracts the key from the value of the cookie.
+func Cookie(name string) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		c, err := r.Cookie(name)
+		if err != nil || c.Value == "" {
+			return "", false
+		}
+		return c.Value, true
+	}
+}
+
+// PathSegment extracts the key from the path segment at the zero based index,
+// ignoring empty segments. For "/tenants/acme/users", index 1 returns "acme".
+func PathSegment(index int) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		if index < 0 {
+			return "", false
+		}
+
+		i := 0
+		for _, segment := range strings.Split(r.URL.Path, "/") {
+			if segment == "" {
+				continue
+			}
+			if i == index {
+				return segment, true
+			}
+			i++
+		}
+		return "", false
+	}
+}
+
+// RemoteIP extracts the key from the IP address of the connection.
+// Use clientip.Resolver to honor the forwarding headers of trusted proxies.
+func RemoteIP() KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return host, host != ""
+	}
+}
+
+// Chain returns the key of the first extractor that finds one,
+// for example a header, then a cookie, then the remote IP.
+func Chain(extractors ...KeyExtractor) KeyExtractor {
+	return func(r *http.Request) (string, bool) {
+		for _, extract := range extractors {
+			if key, ok := extract(r); ok {
+				return key, true
+			}
+		}
+		return "", false
+	}
+}
+
+// Sum64 returns the 64-bit FNV-1a hash of the key,
+// for key based balancers to map a key to a server.
+func Sum64(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
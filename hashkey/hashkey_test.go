@@ -0,0 +1,61 @@
+package hashkey
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyExtractors(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/tenants/acme/users?user=42", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Tenant", "foo")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "bar"})
+
+	tests := []struct {
+		name    string
+		extract KeyExtractor
+		want    string
+		ok      bool
+	}{
+		{name: "header", extract: Header("X-Tenant"), want: "foo", ok: true},
+		{name: "missing header", extract: Header("X-Missing"), ok: false},
+		{name: "query", extract: Query("user"), want: "42", ok: true},
+		{name: "missing query", extract: Query("missing"), ok: false},
+		{name: "cookie", extract: Cookie("session"), want: "bar", ok: true},
+		{name: "missing cookie", extract: Cookie("missing"), ok: false},
+		{name: "path segment", extract: PathSegment(1), want: "acme", ok: true},
+		{name: "path segment out of range", extract: PathSegment(3), ok: false},
+		{name: "negative path segment", extract: PathSegment(-1), ok: false},
+		{name: "remote ip", extract: RemoteIP(), want: "10.0.0.1", ok: true},
+		{
+			name:    "chain falls back",
+			extract: Chain(Header("X-Missing"), Cookie("session"), RemoteIP()),
+			want:    "bar",
+			ok:      true,
+		},
+		{
+			name:    "chain without key",
+			extract: Chain(Header("X-Missing"), Query("missing")),
+			ok:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.extract(req)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("got (%q, %v), want (%q, %v)", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSum64(t *testing.T) {
+	if Sum64("foo") != Sum64("foo") {
+		t.Fatalf("Expected Sum64 to be deterministic")
+	}
+	if Sum64("foo") == Sum64("bar") {
+		t.Fatalf("Expected different keys to hash differently")
+	}
+}
@@ -0,0 +1,385 @@
+package hedge
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+var (
+	// ErrInvalidDelay is returned when the hedging delay is not positive.
+	ErrInvalidDelay = errors.New("hedge: delay must be greater than zero")
+	// ErrInvalidPercentile is returned when the latency percentile is not in the range (0, 1).
+	ErrInvalidPercentile = errors.New("hedge: percentile must be in the range (0, 1)")
+	// ErrInvalidBudget is returned when the hedging budget is not in the range [0, 1].
+	ErrInvalidBudget = errors.New("hedge: budget must be in the range [0, 1]")
+	// ErrInvalidMaxBufferSize is returned when the response buffer limit is negative.
+	ErrInvalidMaxBufferSize = errors.New("hedge: max buffer size must not be negative")
+
+	// errLost is returned to an attempt writing its response after another one was sent.
+	errLost = errors.New("hedge: another response was sent")
+)
+
+const (
+	// Default delay before sending a hedged request
+	defaultDelay = 50 * time.Millisecond
+	// Default maximum fraction of the requests that are hedged
+	defaultBudget = 0.1
+	// Default maximum size of a buffered response body, 1 MiB
+	defaultMaxBufferSize = 1 << 20
+	// Number of latency samples kept to compute the percentile delay
+	sampleSize = 1000
+	// Minimum number of latency samples before the percentile delay is used
+	minSamples = 20
+	// Number of new samples after which the percentile delay is recomputed
+	refreshEvery = 64
+)
+
+// Hedge reduces the tail latency of idempotent requests. If the server chosen
+// by the balancer has not responded within the hedging delay, the same request
+// is sent to a second server and the first response wins; the other request is
+// cancelled through its context.
+//
+// The delay is either fixed or a percentile of the observed latencies, and the
+// hedging budget caps the fraction of the requests that are hedged to avoid
+// amplifying the load. Only GET, HEAD and OPTIONS requests without a body and
+// without a connection upgrade are hedged.
+//
+// The responses are buffered before being written, up to the buffer limit. A
+// response exceeding the limit, or flushed by the proxy, wins: hedging stops,
+// the other requests are cancelled and the response is streamed to the client.
+type Hedge struct {
+	balancer      proxy.Balancer
+	delay         time.Duration
+	percentile    float64
+	budget        float64
+	maxBufferSize int64
+
+	requests uint64
+	hedged   uint64
+
+	mu           sync.Mutex
+	samples      []time.Duration
+	nextSample   int
+	newSamples   int
+	currentDelay int64
+}
+
+// New creates a hedging handler on top of the balancer.
+func New(balancer proxy.Balancer, opts ...Opts) (*Hedge, error) {
+	h := &Hedge{
+		balancer:      balancer,
+		delay:         defaultDelay,
+		budget:        defaultBudget,
+		maxBufferSize: defaultMaxBufferSize,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	switch {
+	case h.delay <= 0:
+		return nil, ErrInvalidDelay
+	case h.percentile < 0 || h.percentile >= 1 || math.IsNaN(h.percentile):
+		return nil, ErrInvalidPercentile
+	case h.budget < 0 || h.budget > 1 || math.IsNaN(h.budget):
+		return nil, ErrInvalidBudget
+	case h.maxBufferSize < 0:
+		return nil, ErrInvalidMaxBufferSize
+	}
+
+	h.currentDelay = int64(h.delay)
+	return h, nil
+}
+
+// Delay returns the current hedging delay.
+func (h *Hedge) Delay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.currentDelay))
+}
+
+// ServeHTTP forwards the request, hedging it if it is idempotent.
+// It responds with 503 Service Unavailable if no server is available.
+func (h *Hedge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	first := h.balancer.NextServer()
+	if first == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	if !hedgeable(r) {
+		first.ServeHTTP(w, r)
+		return
+	}
+
+	atomic.AddUint64(&h.requests, 1)
+
+	race := &race{w: w, streaming: make(chan *response, 1)}
+	results := make(chan *response, 2)
+	attempts := []*response{h.send(r, first, race, results)}
+	defer func() {
+		for _, attempt := range attempts {
+			attempt.cancel()
+		}
+	}()
+
+	timer := time.NewTimer(h.Delay())
+	defer timer.Stop()
+
+	hedge, done := timer.C, r.Context().Done()
+	pending := 1
+	var fallback *response
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.w != nil {
+				// The response was streamed to the client.
+				return
+			}
+			// Prefer a successful response over a server error while another attempt is running.
+			if res.code >= http.StatusInternalServerError && pending > 0 {
+				fallback = res
+				continue
+			}
+			if race.claim(res) {
+				res.writeTo(w)
+				return
+			}
+			// Another attempt is streaming its response; wait for it to finish.
+		case res := <-race.streaming:
+			hedge = nil
+			for _, attempt := range attempts {
+				if attempt != res {
+					attempt.cancel()
+				}
+			}
+		case <-hedge:
+			hedge = nil
+			if second := h.hedgeServer(first); second != nil {
+				attempts = append(attempts, h.send(r, second, race, results))
+				pending++
+			}
+		case <-done:
+			if race.close() {
+				return
+			}
+			// The streaming attempt is cancelled with the request and must
+			// finish writing before the handler returns.
+			done = nil
+		}
+	}
+
+	if fallback != nil && race.claim(fallback) {
+		fallback.writeTo(w)
+	}
+}
+
+// send forwards a copy of the request to the server in the background
+// and returns the attempt.
+func (h *Hedge) send(r *http.Request, server *proxy.Proxy, race *race, results chan<- *response) *response {
+	ctx, cancel := context.WithCancel(r.Context())
+	req := r.Clone(ctx)
+	res := &response{
+		race:    race,
+		cancel:  cancel,
+		maxSize: h.maxBufferSize,
+		header:  make(http.Header),
+		code:    http.StatusOK,
+	}
+
+	go func() {
+		start := time.Now()
+		defer func() {
+			// The reverse proxy aborts the handler when the response of a
+			// cancelled request cannot be copied.
+			if err := recover(); err != nil && err != http.ErrAbortHandler {
+				panic(err)
+			}
+			if ctx.Err() == nil {
+				h.observe(time.Since(start))
+			}
+			results <- res
+		}()
+
+		server.ServeHTTP(res, req)
+	}()
+
+	return res
+}
+
+// hedgeServer returns a server other than first to send the hedged request to,
+// or nil if the budget is exhausted or there is no other server.
+func (h *Hedge) hedgeServer(first *proxy.Proxy) *proxy.Proxy {
+	requests := atomic.LoadUint64(&h.requests)
+	if float64(atomic.LoadUint64(&h.hedged)+1) > h.budget*float64(requests) {
+		return nil
+	}
+
+	count := len(h.balancer.Servers())
+	for i := 0; i < count; i++ {
+		server := h.balancer.NextServer()
+		if server != nil && server != first {
+			atomic.AddUint64(&h.hedged, 1)
+			return server
+		}
+	}
+	return nil
+}
+
+// observe records the latency of a response and refreshes the percentile delay.
+func (h *Hedge) observe(latency time.Duration) {
+	if h.percentile == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.samples) < sampleSize {
+		h.samples = append(h.samples, latency)
+	} else {
+		h.samples[h.nextSample] = latency
+		h.nextSample = (h.nextSample + 1) % sampleSize
+	}
+
+	// The delay is computed once enough samples are available, then periodically.
+	h.newSamples++
+	if len(h.samples) < minSamples || (h.newSamples < refreshEvery && len(h.samples) != minSamples) {
+		return
+	}
+	h.newSamples = 0
+
+	sorted := make([]time.Duration, len(h.samples))
+	copy(sorted, h.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	delay := sorted[int(float64(len(sorted)-1)*h.percentile)]
+	if delay <= 0 {
+		delay = h.delay
+	}
+	atomic.StoreInt64(&h.currentDelay, int64(delay))
+}
+
+// hedgeable reports whether the request is idempotent, has no body and
+// does not upgrade the connection.
+func hedgeable(r *http.Request) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	if proxy.IsUpgrade(r) {
+		return false
+	}
+	return r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0
+}
+
+// race decides which attempt writes its response to the client.
+type race struct {
+	w http.ResponseWriter
+	// streaming receives the attempt that started streaming its response.
+	streaming chan *response
+
+	mu     sync.Mutex
+	winner *response
+	closed bool
+}
+
+// claim reports whether the response can be written to the client.
+// Only the first response claimed is written.
+func (c *race) claim(r *response) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.winner == nil && !c.closed {
+		c.winner = r
+	}
+	return c.winner == r
+}
+
+// close prevents any later claim, and reports whether no response was claimed.
+func (c *race) close() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return c.winner == nil
+}
+
+// response is the http.ResponseWriter of an attempt. It buffers the response
+// until it exceeds the size limit or is flushed, then streams it to the client.
+type response struct {
+	race    *race
+	cancel  context.CancelFunc
+	maxSize int64
+
+	header      http.Header
+	code        int
+	wroteHeader bool
+	body        []byte
+	// w is the client writer once the response is streamed.
+	w http.ResponseWriter
+}
+
+func (r *response) Header() http.Header {
+	if r.w != nil {
+		// Trailers are set after the body is written.
+		return r.w.Header()
+	}
+	return r.header
+}
+
+func (r *response) WriteHeader(code int) {
+	if r.wroteHeader {
+		return
+	}
+	r.wroteHeader = true
+	r.code = code
+}
+
+func (r *response) Write(b []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if r.w == nil && int64(len(r.body)+len(b)) <= r.maxSize {
+		r.body = append(r.body, b...)
+		return len(b), nil
+	}
+	if r.w == nil && !r.stream() {
+		return 0, errLost
+	}
+	return r.w.Write(b)
+}
+
+// Flush streams the response to the client, unless it is a server error
+// which is kept buffered in case another attempt succeeds.
+func (r *response) Flush() {
+	if r.w == nil && (r.code >= http.StatusInternalServerError || !r.stream()) {
+		return
+	}
+	_ = http.NewResponseController(r.w).Flush()
+}
+
+// stream claims the client for the response and writes the buffered response to it.
+// It reports false if another response was sent.
+func (r *response) stream() bool {
+	if !r.race.claim(r) {
+		return false
+	}
+	r.writeTo(r.race.w)
+	r.w, r.body = r.race.w, nil
+	r.race.streaming <- r
+	return true
+}
+
+// writeTo writes the buffered response to w.
+func (r *response) writeTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.code)
+	_, _ = w.Write(r.body)
+}
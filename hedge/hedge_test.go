@@ -0,0 +1,245 @@
+package hedge
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// sequence is a balancer returning its servers in order.
+type sequence struct {
+	mu      sync.Mutex
+	servers []*proxy.Proxy
+	next    int
+}
+
+func (s *sequence) NextServer() *proxy.Proxy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.servers) == 0 {
+		return nil
+	}
+	server := s.servers[s.next%len(s.servers)]
+	s.next++
+	return server
+}
+
+func (s *sequence) Servers() []*proxy.Proxy {
+	return s.servers
+}
+
+func newServer(t *testing.T, handler http.HandlerFunc) *url.URL {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	addr, _ := url.Parse(ts.URL)
+	return addr
+}
+
+// newBackends returns a slow and a fast proxy. The slow backend responds
+// after 500 milliseconds and reports on cancelled when its request is cancelled.
+func newBackends(t *testing.T) (slow, fast *proxy.Proxy, cancelled chan struct{}) {
+	t.Helper()
+	cancelled = make(chan struct{}, 10)
+	slowAddr := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data" {
+			return
+		}
+		select {
+		case <-time.After(500 * time.Millisecond):
+			_, _ = w.Write([]byte("slow"))
+		case <-r.Context().Done():
+			cancelled <- struct{}{}
+		}
+	})
+	fastAddr := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("fast"))
+	})
+
+	slow = proxy.NewProxy("slow", slowAddr)
+	fast = proxy.NewProxy("fast", fastAddr)
+	t.Cleanup(func() {
+		_ = slow.Close()
+		_ = fast.Close()
+	})
+	return slow, fast, cancelled
+}
+
+func TestNew(t *testing.T) {
+	pool, _ := roundrobin.New()
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "invalid delay", opts: []Opts{WithDelay(0)}, err: ErrInvalidDelay},
+		{name: "invalid percentile", opts: []Opts{WithPercentile(1)}, err: ErrInvalidPercentile},
+		{name: "invalid budget", opts: []Opts{WithBudget(1.5)}, err: ErrInvalidBudget},
+		{name: "invalid max buffer size", opts: []Opts{WithMaxBufferSize(-1)}, err: ErrInvalidMaxBufferSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(pool, tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestHedge_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		budget float64
+		want   string
+		cancel bool
+	}{
+		{name: "hedged", method: http.MethodGet, budget: 1, want: "fast", cancel: true},
+		{name: "budget exhausted", method: http.MethodGet, budget: 0, want: "slow"},
+		{name: "not idempotent", method: http.MethodPost, budget: 1, want: "slow"},
+		{
+			name:   "upgrade",
+			method: http.MethodGet,
+			header: http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"websocket"}},
+			budget: 1,
+			want:   "slow",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slow, fast, cancelled := newBackends(t)
+			h, err := New(&sequence{servers: []*proxy.Proxy{slow, fast}},
+				WithDelay(10*time.Millisecond), WithBudget(tt.budget))
+			if err != nil {
+				t.Fatalf("Failed to create hedge: %v", err)
+			}
+
+			req := httptest.NewRequest(tt.method, "http://example.com/data", nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %d %q, want 200 %q", rec.Code, rec.Body.String(), tt.want)
+			}
+
+			if !tt.cancel {
+				return
+			}
+			select {
+			case <-cancelled:
+			case <-time.After(time.Second):
+				t.Error("The slow request was not cancelled")
+			}
+		})
+	}
+}
+
+func TestHedge_Stream(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+		flushed bool
+	}{
+		{
+			name: "buffer exceeded",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "13")
+				_, _ = w.Write([]byte("0123456789"))
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+				_, _ = w.Write([]byte("end"))
+			},
+			want: "0123456789end",
+		},
+		{
+			name: "flushed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				_, _ = w.Write([]byte("data: 1\n\n"))
+				w.(http.Flusher).Flush()
+				time.Sleep(50 * time.Millisecond)
+				_, _ = w.Write([]byte("data: 2\n\n"))
+			},
+			want:    "data: 1\n\ndata: 2\n\n",
+			flushed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hedged atomic.Bool
+			streaming := proxy.NewProxy("streaming", newServer(t, tt.handler))
+			other := proxy.NewProxy("other", newServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/data" {
+					hedged.Store(true)
+				}
+			}))
+			t.Cleanup(func() {
+				_ = streaming.Close()
+				_ = other.Close()
+			})
+
+			h, err := New(&sequence{servers: []*proxy.Proxy{streaming, other}},
+				WithDelay(10*time.Millisecond), WithBudget(1), WithMaxBufferSize(4))
+			if err != nil {
+				t.Fatalf("Failed to create hedge: %v", err)
+			}
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/data", nil))
+
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() = %d %q, want 200 %q", rec.Code, rec.Body.String(), tt.want)
+			}
+			if rec.Flushed != tt.flushed {
+				t.Errorf("Flushed = %v, want %v", rec.Flushed, tt.flushed)
+			}
+			if hedged.Load() {
+				t.Error("Expected no hedged request once the response is streamed")
+			}
+		})
+	}
+}
+
+func TestHedge_NoServer(t *testing.T) {
+	h, _ := New(&sequence{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHedge_Percentile(t *testing.T) {
+	h, _ := New(&sequence{}, WithDelay(time.Second), WithPercentile(0.95))
+
+	for i := 1; i < minSamples; i++ {
+		h.observe(time.Duration(i) * time.Millisecond)
+	}
+	if got := h.Delay(); got != time.Second {
+		t.Errorf("Delay() = %v before enough samples, want %v", got, time.Second)
+	}
+
+	h.observe(minSamples * time.Millisecond)
+	if got, want := h.Delay(), 19*time.Millisecond; got != want {
+		t.Errorf("Delay() = %v, want %v", got, want)
+	}
+}
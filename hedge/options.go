@@ -0,0 +1,40 @@
+package hedge
+
+import "time"
+
+// Opts configures a Hedge.
+type Opts func(*Hedge)
+
+// WithDelay sets the time to wait for a response before sending the hedged request.
+// When a percentile is set, it is used until enough latencies are observed.
+// Defaults to 50 milliseconds.
+func WithDelay(delay time.Duration) Opts {
+	return func(h *Hedge) {
+		h.delay = delay
+	}
+}
+
+// WithPercentile sets the hedging delay to a percentile of the observed
+// latencies, for example 0.95 for the p95 latency.
+func WithPercentile(percentile float64) Opts {
+	return func(h *Hedge) {
+		h.percentile = percentile
+	}
+}
+
+// WithBudget sets the maximum fraction of the requests that are hedged,
+// in the range [0, 1]. Defaults to 0.1.
+func WithBudget(budget float64) Opts {
+	return func(h *Hedge) {
+		h.budget = budget
+	}
+}
+
+// WithMaxBufferSize sets the maximum size of a response body that is buffered.
+// A larger response stops the hedging and is streamed to the client.
+// Defaults to 1 MiB.
+func WithMaxBufferSize(size int64) Opts {
+	return func(h *Hedge) {
+		h.maxBufferSize = size
+	}
+}
@@ -0,0 +1,39 @@
+// Package proxytest provides a proxy fixture for the tests of the load balancers.
+package proxytest
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+)
+
+// NewProxy creates a proxy with the given name as address host, whose health
+// state stays the given state. The proxy is closed when the test completes.
+func NewProxy(t testing.TB, name string, state health.State, opts ...proxy.Opts) *proxy.Proxy {
+	t.Helper()
+	return NewProxyWithAddr(t, name, &url.URL{Host: name}, state, opts...)
+}
+
+// NewProxyWithAddr is like NewProxy with the address of the proxy origin.
+// The health check never runs, so it does not send requests to the origin.
+func NewProxyWithAddr(t testing.TB, name string, addr *url.URL, state health.State, opts ...proxy.Opts) *proxy.Proxy {
+	t.Helper()
+	healthOpts := []health.Opts{
+		health.WithCheck(func(*url.URL) error { return nil }),
+		health.WithInitialDelay(time.Hour),
+	}
+	if state != health.StateUnknown {
+		healthOpts = append(healthOpts, health.WithInitialState(state))
+	}
+	h, err := health.New(addr, healthOpts...)
+	if err != nil {
+		t.Fatalf("Failed to create health check: %v", err)
+	}
+
+	p := proxy.NewProxy(name, addr, append(opts, proxy.WithHealth(h))...)
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
@@ -0,0 +1,52 @@
+// Package sampler selects an exact fraction of a stream of events.
+package sampler
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Scale is the resolution of the rate, in basis points.
+const Scale = 10000
+
+// Sampler selects an exact fraction of the events: out of every Scale events,
+// exactly rate*Scale are selected, evenly spread. The rate can be changed
+// concurrently with the sampling. The zero value selects no event.
+type Sampler struct {
+	// basisPoints is the rate multiplied by Scale.
+	basisPoints uint64
+	counter     uint64
+}
+
+// SetRate sets the fraction of the events to select, in the range [0, 1].
+// The caller validates the rate.
+func (s *Sampler) SetRate(rate float64) {
+	atomic.StoreUint64(&s.basisPoints, uint64(math.Round(rate*Scale)))
+}
+
+// Rate returns the fraction of the events selected.
+func (s *Sampler) Rate() float64 {
+	return float64(atomic.LoadUint64(&s.basisPoints)) / Scale
+}
+
+// Sample reports whether the next event is selected.
+func (s *Sampler) Sample() bool {
+	bp := atomic.LoadUint64(&s.basisPoints)
+	switch bp {
+	case 0:
+		return false
+	case Scale:
+		return true
+	}
+
+	// Select the event each time the running total
+	// of the selected share crosses a whole event.
+	n := atomic.AddUint64(&s.counter, 1) - 1
+	return (n+1)*bp/Scale > n*bp/Scale
+}
+
+// SampleHash reports whether the event with the hash is selected, so that
+// the same hash is always selected for a given rate.
+func (s *Sampler) SampleHash(hash uint64) bool {
+	return hash%Scale < atomic.LoadUint64(&s.basisPoints)
+}
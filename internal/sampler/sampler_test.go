@@ -0,0 +1,49 @@
+package sampler
+
+import "testing"
+
+func TestSampler_Sample(t *testing.T) {
+	tests := []struct {
+		name string
+		rate float64
+		want int
+	}{
+		{name: "none", rate: 0, want: 0},
+		{name: "all", rate: 1, want: Scale},
+		{name: "tenth", rate: 0.1, want: Scale / 10},
+		{name: "basis point", rate: 0.0001, want: 1},
+		{name: "two thirds", rate: 0.6667, want: 6667},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s Sampler
+			s.SetRate(tt.rate)
+
+			got := 0
+			for i := 0; i < Scale; i++ {
+				if s.Sample() {
+					got++
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Sample() selected %d/%d, want %d", got, Scale, tt.want)
+			}
+			if s.Rate() != tt.rate {
+				t.Errorf("Rate() = %v, want %v", s.Rate(), tt.rate)
+			}
+		})
+	}
+}
+
+func TestSampler_SampleHash(t *testing.T) {
+	var s Sampler
+	s.SetRate(0.5)
+
+	if !s.SampleHash(Scale + 10) {
+		t.Error("Expected a hash below the rate to be selected")
+	}
+	if s.SampleHash(7000) {
+		t.Error("Expected a hash above the rate not to be selected")
+	}
+}
@@ -0,0 +1,204 @@
+package keyhash
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/appleboy/loadbalancer-algorithms/hashkey"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// ErrKeyEmpty is returned when no key extractor is provided.
+var ErrKeyEmpty = errors.New("keyhash: key extractor is empty")
+
+// KeyHash routes each request to a server chosen by hashing a key extracted
+// from the request, such as a tenant header or the client IP, so that the
+// requests with the same key land on the same server.
+//
+// The server is chosen by rendezvous hashing: every server is scored by the
+// hash of its name and the key, and the highest score wins. Adding or
+// removing a server only moves the keys of that server. The requests
+// without a key are distributed in round-robin order.
+type KeyHash struct {
+	sync.RWMutex
+	key     hashkey.KeyExtractor
+	servers []*proxy.Proxy
+	next    uint32
+
+	// healthyOnly skips servers that are not available.
+	healthyOnly bool
+	// includeUnknown treats servers with an unknown health state as available.
+	includeUnknown bool
+}
+
+// New creates a key hash load balancer with the key extractor and the servers.
+func New(key hashkey.KeyExtractor, servers []*proxy.Proxy, opts ...Opts) (*KeyHash, error) {
+	if key == nil {
+		return nil, ErrKeyEmpty
+	}
+
+	k := &KeyHash{
+		key:     key,
+		servers: make([]*proxy.Proxy, len(servers)),
+	}
+	copy(k.servers, servers)
+	for _, server := range servers {
+		server.Start()
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	return k, nil
+}
+
+// NextServer returns the server of the key of the request. With
+// WithHealthyOnly, the available server with the highest score is returned,
+// so only the keys of an unavailable server move. It returns nil if no
+// server is available.
+func (k *KeyHash) NextServer(r *http.Request) *proxy.Proxy {
+	key, ok := k.key(r)
+
+	k.RLock()
+	defer k.RUnlock()
+
+	count := uint32(len(k.servers))
+	if count == 0 {
+		return nil
+	}
+
+	if !ok {
+		index := atomic.AddUint32(&k.next, 1)
+		for i := uint32(0); i < count; i++ {
+			server := k.servers[(index-1+i)%count]
+			if k.available(server) {
+				return server
+			}
+		}
+		return nil
+	}
+
+	sum := hashkey.Sum64(key)
+	var best *proxy.Proxy
+	var bestScore uint64
+	for _, server := range k.servers {
+		if !k.available(server) {
+			continue
+		}
+		if s := score(hashkey.Sum64(server.GetName()), sum); best == nil || s > bestScore {
+			best, bestScore = server, s
+		}
+	}
+	return best
+}
+
+// score returns the score of a server for a key from their hashes. The hashes
+// are mixed with the SplitMix64 finalizer, as FNV alone spreads the keys
+// poorly over servers whose names differ only slightly.
+func score(server, key uint64) uint64 {
+	x := server ^ key
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// available reports whether the server can be selected.
+func (k *KeyHash) available(server *proxy.Proxy) bool {
+	if !k.healthyOnly {
+		return true
+	}
+	switch server.State() {
+	case health.StateHealthy:
+		return true
+	case health.StateUnknown:
+		return k.includeUnknown
+	default:
+		return false
+	}
+}
+
+// ServeHTTP forwards the request to the server selected by NextServer.
+// It responds with 503 Service Unavailable if no server is available.
+func (k *KeyHash) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := k.NextServer(r)
+	if server == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// AddServers adds the servers and starts their health checks.
+func (k *KeyHash) AddServers(servers ...*proxy.Proxy) error {
+	if len(servers) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	for _, server := range servers {
+		server.Start()
+	}
+	k.servers = append(k.servers, servers...)
+	return nil
+}
+
+// RemoveServers removes the servers and stops their health checks.
+func (k *KeyHash) RemoveServers(names ...string) error {
+	if len(names) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	remove := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		remove[name] = struct{}{}
+	}
+
+	k.Lock()
+	defer k.Unlock()
+
+	kept := k.servers[:0]
+	for _, server := range k.servers {
+		if _, ok := remove[server.GetName()]; ok {
+			_ = server.Close()
+			continue
+		}
+		kept = append(kept, server)
+	}
+	for i := len(kept); i < len(k.servers); i++ {
+		k.servers[i] = nil
+	}
+	k.servers = kept
+	return nil
+}
+
+// Servers returns a list of all servers.
+func (k *KeyHash) Servers() []*proxy.Proxy {
+	k.RLock()
+	defer k.RUnlock()
+
+	servers := make([]*proxy.Proxy, len(k.servers))
+	copy(servers, k.servers)
+	return servers
+}
+
+// RemoveAll removes all servers and stops their health checks.
+func (k *KeyHash) RemoveAll() {
+	k.Lock()
+	defer k.Unlock()
+
+	for _, server := range k.servers {
+		_ = server.Close()
+	}
+	k.servers = nil
+}
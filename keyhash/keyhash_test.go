@@ -0,0 +1,160 @@
+package keyhash
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/clientip"
+	"github.com/appleboy/loadbalancer-algorithms/hashkey"
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+)
+
+func newRequest(tenant string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if tenant != "" {
+		req.Header.Set("X-Tenant", tenant)
+	}
+	return req
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, nil); !errors.Is(err, ErrKeyEmpty) {
+		t.Fatalf("New() error = %v, want %v", err, ErrKeyEmpty)
+	}
+}
+
+func TestKeyHash_NextServer(t *testing.T) {
+	servers := []*proxy.Proxy{
+		proxytest.NewProxy(t, "a", health.StateHealthy),
+		proxytest.NewProxy(t, "b", health.StateHealthy),
+		proxytest.NewProxy(t, "c", health.StateHealthy),
+	}
+	k, err := New(hashkey.Header("X-Tenant"), servers)
+	if err != nil {
+		t.Fatalf("Failed to create key hash: %v", err)
+	}
+
+	// The requests with the same key land on the same server.
+	before := map[string]string{}
+	used := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		tenant := fmt.Sprintf("tenant-%d", i)
+		name := k.NextServer(newRequest(tenant)).GetName()
+		if again := k.NextServer(newRequest(tenant)).GetName(); again != name {
+			t.Fatalf("Expected %s for %s, but got %s", name, tenant, again)
+		}
+		before[tenant] = name
+		used[name] = true
+	}
+	if len(used) != 3 {
+		t.Fatalf("Expected the keys to spread over 3 servers, but got %v", used)
+	}
+
+	// Removing a server only moves its keys.
+	if err := k.RemoveServers("b"); err != nil {
+		t.Fatalf("Failed to remove servers: %v", err)
+	}
+	for tenant, name := range before {
+		got := k.NextServer(newRequest(tenant)).GetName()
+		if name != "b" && got != name {
+			t.Fatalf("Expected %s to stay on %s, but got %s", tenant, name, got)
+		}
+		if got == "b" {
+			t.Fatalf("Unexpected removed server for %s", tenant)
+		}
+	}
+
+	// The requests without a key are distributed in round-robin order.
+	seen := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		seen[k.NextServer(newRequest("")).GetName()] = true
+	}
+	if len(seen) != 2 {
+		t.Fatalf("Expected round-robin for requests without a key, but got %v", seen)
+	}
+}
+
+func TestKeyHash_HealthyOnly(t *testing.T) {
+	a := proxytest.NewProxy(t, "a", health.StateHealthy)
+	b := proxytest.NewProxy(t, "b", health.StateUnhealthy)
+	c := proxytest.NewProxy(t, "c", health.StateUnknown)
+
+	tests := []struct {
+		name string
+		opts []Opts
+		want map[string]bool
+	}{
+		{name: "healthy", opts: []Opts{WithHealthyOnly(false)}, want: map[string]bool{"a": true}},
+		{name: "include unknown", opts: []Opts{WithHealthyOnly(true)}, want: map[string]bool{"a": true, "c": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, _ := New(hashkey.Header("X-Tenant"), []*proxy.Proxy{a, b, c}, tt.opts...)
+			for i := 0; i < 50; i++ {
+				for _, tenant := range []string{fmt.Sprintf("tenant-%d", i), ""} {
+					if name := k.NextServer(newRequest(tenant)).GetName(); !tt.want[name] {
+						t.Fatalf("Unexpected server %s for %q", name, tenant)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestKeyHash_ClientIP(t *testing.T) {
+	resolver, err := clientip.New(clientip.WithTrustedProxies("10.0.0.0/8"), clientip.WithIPv6Prefix(64))
+	if err != nil {
+		t.Fatalf("Failed to create resolver: %v", err)
+	}
+
+	servers := []*proxy.Proxy{
+		proxytest.NewProxy(t, "a", health.StateHealthy),
+		proxytest.NewProxy(t, "b", health.StateHealthy),
+		proxytest.NewProxy(t, "c", health.StateHealthy),
+	}
+	k, _ := New(resolver.KeyExtractor(), servers)
+
+	send := func(remoteAddr, forwardedFor string) string {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		return k.NextServer(req).GetName()
+	}
+
+	// The clients behind the trusted proxy are hashed by their own address.
+	used := map[string]bool{}
+	for i := 0; i < 50; i++ {
+		client := fmt.Sprintf("203.0.113.%d", i)
+		name := send("10.0.0.1:1234", client)
+		if direct := send(client+":1234", ""); direct != name {
+			t.Fatalf("Expected %s for %s behind the proxy, but got %s", direct, client, name)
+		}
+		used[name] = true
+	}
+	if len(used) < 2 {
+		t.Fatalf("Expected the clients behind the proxy to spread, but got %v", used)
+	}
+
+	// The addresses of an IPv6 /64 network are hashed to the same server.
+	if a, b := send("[2001:db8::1]:1234", ""), send("[2001:db8::2]:1234", ""); a != b {
+		t.Fatalf("Expected the same server for the /64 network, but got %s and %s", a, b)
+	}
+}
+
+func TestKeyHash_ServeHTTP(t *testing.T) {
+	k, _ := New(hashkey.Header("X-Tenant"), nil)
+
+	rec := httptest.NewRecorder()
+	k.ServeHTTP(rec, newRequest("a"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
@@ -0,0 +1,14 @@
+package keyhash
+
+// Opts configures a KeyHash.
+type Opts func(*KeyHash)
+
+// WithHealthyOnly makes NextServer skip servers that are not available.
+// Servers whose health state is still unknown are selected only if
+// includeUnknown is true.
+func WithHealthyOnly(includeUnknown bool) Opts {
+	return func(k *KeyHash) {
+		k.healthyOnly = true
+		k.includeUnknown = includeUnknown
+	}
+}
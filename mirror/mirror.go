@@ -0,0 +1,178 @@
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/internal/sampler"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+var (
+	// ErrInvalidSampleRate is returned when the sample rate is not in the range [0, 1].
+	ErrInvalidSampleRate = errors.New("mirror: sample rate must be in the range [0, 1]")
+	// ErrInvalidMaxConcurrent is returned when the concurrency limit is less than 1.
+	ErrInvalidMaxConcurrent = errors.New("mirror: max concurrent must be at least 1")
+	// ErrInvalidMaxBodySize is returned when the body size limit is negative.
+	ErrInvalidMaxBodySize = errors.New("mirror: max body size must not be negative")
+)
+
+const (
+	// Default number of concurrent shadow requests
+	defaultMaxConcurrent = 16
+	// Default maximum size of a mirrored request body, 1 MiB
+	defaultMaxBodySize = 1 << 20
+	// Default timeout of a shadow request
+	defaultTimeout = 10 * time.Second
+)
+
+// Ensure that Mirror implements the proxy.Mirror interface.
+var _ proxy.Mirror = (*Mirror)(nil)
+
+// Mirror sends a copy of a sample of the requests to a shadow pool,
+// fire-and-forget, and discards the shadow responses. It is attached to a
+// proxy with proxy.WithMirror.
+//
+// The primary request is never slowed down by the shadow pool: requests are
+// skipped when the concurrency limit is reached, and bodies larger than the
+// size limit are not mirrored. The body of a sampled request is read up to
+// the size limit before the request is forwarded to the primary backend, so
+// a client sending its body slowly delays the primary request as well.
+//
+// Upgrade requests, such as WebSockets, are not mirrored: the shadow
+// responses are discarded, so the connection cannot be taken over.
+type Mirror struct {
+	pool          proxy.Balancer
+	sampleRate    float64
+	maxConcurrent int
+	maxBodySize   int64
+	timeout       time.Duration
+
+	sampler sampler.Sampler
+	sem     chan struct{}
+}
+
+// New creates a Mirror sending shadow requests to the pool.
+func New(pool proxy.Balancer, opts ...Opts) (*Mirror, error) {
+	m := &Mirror{
+		pool:          pool,
+		sampleRate:    1,
+		maxConcurrent: defaultMaxConcurrent,
+		maxBodySize:   defaultMaxBodySize,
+		timeout:       defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	switch {
+	case m.sampleRate < 0 || m.sampleRate > 1 || math.IsNaN(m.sampleRate):
+		return nil, ErrInvalidSampleRate
+	case m.maxConcurrent < 1:
+		return nil, ErrInvalidMaxConcurrent
+	case m.maxBodySize < 0:
+		return nil, ErrInvalidMaxBodySize
+	}
+
+	m.sampler.SetRate(m.sampleRate)
+	m.sem = make(chan struct{}, m.maxConcurrent)
+	return m, nil
+}
+
+// Mirror sends a copy of the request to the shadow pool if it is sampled,
+// and returns the request to forward to the primary backend.
+func (m *Mirror) Mirror(r *http.Request) *http.Request {
+	if proxy.IsUpgrade(r) {
+		return r
+	}
+	if !m.sampler.Sample() {
+		return r
+	}
+	if r.ContentLength > m.maxBodySize {
+		return r
+	}
+
+	select {
+	case m.sem <- struct{}{}:
+	default:
+		// Too many shadow requests in flight.
+		return r
+	}
+
+	body, r, ok := m.bufferBody(r)
+	if !ok {
+		<-m.sem
+		return r
+	}
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), m.timeout)
+	shadow := r.Clone(ctx)
+	shadow.Body = http.NoBody
+	if body != nil {
+		shadow.Body = io.NopCloser(bytes.NewReader(body))
+		shadow.ContentLength = int64(len(body))
+	}
+
+	go func() {
+		defer func() { <-m.sem }()
+		defer cancel()
+		defer func() {
+			// The reverse proxy aborts the handler when the shadow response
+			// cannot be copied, which must not crash the process.
+			if err := recover(); err != nil && err != http.ErrAbortHandler {
+				panic(err)
+			}
+		}()
+
+		server := m.pool.NextServer()
+		if server == nil {
+			return
+		}
+		server.ServeHTTP(&discard{header: make(http.Header)}, shadow)
+	}()
+
+	return r
+}
+
+// bufferBody reads the request body up to the size limit. It returns the body,
+// the request with its body restored, and false if the body is too large.
+func (m *Mirror) bufferBody(r *http.Request) ([]byte, *http.Request, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, r, true
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, m.maxBodySize+1))
+	restored := r.WithContext(r.Context())
+	restored.Body = &readCloser{
+		Reader: io.MultiReader(bytes.NewReader(body), r.Body),
+		Closer: r.Body,
+	}
+
+	if err != nil || int64(len(body)) > m.maxBodySize {
+		return nil, restored, false
+	}
+	return body, restored, true
+}
+
+// readCloser combines a reader with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// discard is a http.ResponseWriter that discards the response.
+type discard struct {
+	header http.Header
+}
+
+func (d *discard) Header() http.Header { return d.header }
+
+func (d *discard) Write(b []byte) (int, error) { return len(b), nil }
+
+func (d *discard) WriteHeader(int) {}
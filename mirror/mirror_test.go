@@ -0,0 +1,169 @@
+package mirror
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+func newServer(t *testing.T, handler http.HandlerFunc) *url.URL {
+	t.Helper()
+	ts := httptest.NewServer(handler)
+	t.Cleanup(ts.Close)
+	addr, _ := url.Parse(ts.URL)
+	return addr
+}
+
+func TestNew(t *testing.T) {
+	pool, _ := roundrobin.New()
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "invalid sample rate", opts: []Opts{WithSampleRate(1.5)}, err: ErrInvalidSampleRate},
+		{name: "invalid max concurrent", opts: []Opts{WithMaxConcurrent(0)}, err: ErrInvalidMaxConcurrent},
+		{name: "invalid max body size", opts: []Opts{WithMaxBodySize(-1)}, err: ErrInvalidMaxBodySize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(pool, tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestMirror(t *testing.T) {
+	shadowBodies := make(chan string, 10)
+	release := make(chan struct{})
+	shadowAddr := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// Ignore the health checks of the shadow proxy.
+		if r.Method != http.MethodPost {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		shadowBodies <- string(body)
+		<-release
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer close(release)
+
+	primaryAddr := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+
+	pool, _ := roundrobin.New(proxy.NewProxy("shadow", shadowAddr))
+	defer pool.RemoveAll()
+
+	m, err := New(pool, WithMaxBodySize(8), WithMaxConcurrent(1))
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+
+	p := proxy.NewProxy("primary", primaryAddr, proxy.WithMirror(m))
+	defer p.Close()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "http://example.com", io.NopCloser(strings.NewReader(body)))
+		req.ContentLength = -1
+		p.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// The primary response is not delayed by the blocked shadow request.
+	start := time.Now()
+	if rec := send("hello"); rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("unexpected primary response: %d %q", rec.Code, rec.Body.String())
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("Primary request was delayed by the shadow request")
+	}
+
+	select {
+	case body := <-shadowBodies:
+		if body != "hello" {
+			t.Fatalf("Expected shadow body %q, but got %q", "hello", body)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the request to be mirrored")
+	}
+
+	// The concurrency limit is reached, the request is not mirrored.
+	if rec := send("world"); rec.Body.String() != "world" {
+		t.Fatalf("unexpected primary response: %q", rec.Body.String())
+	}
+
+	// A body above the size limit is forwarded in full but not mirrored.
+	release <- struct{}{}
+	time.Sleep(50 * time.Millisecond)
+	if rec := send("a large request body"); rec.Body.String() != "a large request body" {
+		t.Fatalf("unexpected primary response: %q", rec.Body.String())
+	}
+
+	select {
+	case body := <-shadowBodies:
+		t.Fatalf("Unexpected mirrored request with body %q", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirror_Upgrade(t *testing.T) {
+	mirrored := make(chan struct{}, 1)
+	shadowAddr := newServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			mirrored <- struct{}{}
+		}
+	})
+
+	pool, _ := roundrobin.New(proxy.NewProxy("shadow", shadowAddr))
+	defer pool.RemoveAll()
+
+	m, err := New(pool)
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if got := m.Mirror(req); got != req {
+		t.Fatalf("Expected the upgrade request to be forwarded unchanged")
+	}
+
+	select {
+	case <-mirrored:
+		t.Fatalf("Unexpected mirrored upgrade request")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMirror_SampleRate(t *testing.T) {
+	pool, _ := roundrobin.New()
+	m, err := New(pool, WithSampleRate(0.1))
+	if err != nil {
+		t.Fatalf("Failed to create mirror: %v", err)
+	}
+
+	n := 0
+	for i := 0; i < 100; i++ {
+		if m.sampler.Sample() {
+			n++
+		}
+	}
+	if n != 10 {
+		t.Fatalf("Expected 10/100 sampled requests, but got %d", n)
+	}
+}
@@ -0,0 +1,37 @@
+package mirror
+
+import "time"
+
+// Opts configures a Mirror.
+type Opts func(*Mirror)
+
+// WithSampleRate sets the fraction of the requests that are mirrored,
+// in the range [0, 1]. Defaults to 1.
+func WithSampleRate(rate float64) Opts {
+	return func(m *Mirror) {
+		m.sampleRate = rate
+	}
+}
+
+// WithMaxConcurrent sets the maximum number of shadow requests in flight.
+// Requests are not mirrored while the limit is reached. Defaults to 16.
+func WithMaxConcurrent(n int) Opts {
+	return func(m *Mirror) {
+		m.maxConcurrent = n
+	}
+}
+
+// WithMaxBodySize sets the maximum size of a request body that is buffered
+// for mirroring. Larger requests are not mirrored. Defaults to 1 MiB.
+func WithMaxBodySize(size int64) Opts {
+	return func(m *Mirror) {
+		m.maxBodySize = size
+	}
+}
+
+// WithTimeout sets the timeout of the shadow requests. Defaults to 10 seconds.
+func WithTimeout(timeout time.Duration) Opts {
+	return func(m *Mirror) {
+		m.timeout = timeout
+	}
+}
@@ -0,0 +1,12 @@
+package priority
+
+// Opts configures a Priority.
+type Opts func(*Priority)
+
+// WithThreshold sets the healthy fraction of a tier below which the traffic
+// fails over to the next tier. It must be in the range (0, 1]. Defaults to 0.7.
+func WithThreshold(threshold float64) Opts {
+	return func(p *Priority) {
+		p.threshold = threshold
+	}
+}
@@ -0,0 +1,121 @@
+package priority
+
+import (
+	"errors"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+var (
+	// ErrTiersEmpty is returned when no tier is provided.
+	ErrTiersEmpty = errors.New("priority: tier list is empty")
+	// ErrInvalidThreshold is returned when the threshold is not in the range (0, 1].
+	ErrInvalidThreshold = errors.New("priority: threshold must be in the range (0, 1]")
+)
+
+// Default healthy fraction below which traffic fails over to the next tier,
+// matching the Envoy overprovisioning factor of 1.4.
+const defaultThreshold = 0.7
+
+// Ensure that Priority implements the proxy.Balancer interface.
+var _ proxy.Balancer = (*Priority)(nil)
+
+// Priority balances the traffic over tiers of servers ordered by priority,
+// such as a primary data center, a secondary data center and a last resort
+// static page. All traffic goes to the first tier whose healthy fraction is
+// at least the threshold. If no tier is healthy enough, the traffic goes to
+// the first tier with an available server, and finally to the last tier.
+type Priority struct {
+	tiers     []roundrobin.RoundRobin
+	threshold float64
+}
+
+// New creates a priority load balancer over the tiers, the first one having the highest priority.
+func New(tiers []roundrobin.RoundRobin, opts ...Opts) (*Priority, error) {
+	if len(tiers) == 0 {
+		return nil, ErrTiersEmpty
+	}
+
+	p := &Priority{
+		tiers:     make([]roundrobin.RoundRobin, len(tiers)),
+		threshold: defaultThreshold,
+	}
+	copy(p.tiers, tiers)
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	if p.threshold <= 0 || p.threshold > 1 {
+		return nil, ErrInvalidThreshold
+	}
+
+	return p, nil
+}
+
+// NextServer returns the next server of the active tier, preferring available servers.
+// It returns nil if there are no servers.
+func (p *Priority) NextServer() *proxy.Proxy {
+	tier := p.Tier(p.ActiveTier())
+
+	count := len(tier.Servers())
+	for i := 0; i < count; i++ {
+		server := tier.NextServer()
+		if server == nil {
+			return nil
+		}
+		if server.IsAvailable() {
+			return server
+		}
+	}
+
+	return tier.NextServer()
+}
+
+// ActiveTier returns the index of the tier currently receiving the traffic.
+func (p *Priority) ActiveTier() int {
+	firstAvailable := -1
+	for i, tier := range p.tiers {
+		healthy, total := healthyCount(tier.Servers())
+		if total == 0 {
+			continue
+		}
+		if float64(healthy)/float64(total) >= p.threshold {
+			return i
+		}
+		if healthy > 0 && firstAvailable < 0 {
+			firstAvailable = i
+		}
+	}
+
+	if firstAvailable >= 0 {
+		return firstAvailable
+	}
+	return len(p.tiers) - 1
+}
+
+// Tier returns the tier at index i.
+func (p *Priority) Tier(i int) roundrobin.RoundRobin {
+	return p.tiers[i]
+}
+
+// Servers returns a list of all servers of all tiers, in priority order.
+func (p *Priority) Servers() []*proxy.Proxy {
+	var servers []*proxy.Proxy
+	for _, tier := range p.tiers {
+		servers = append(servers, tier.Servers()...)
+	}
+	return servers
+}
+
+// healthyCount returns the number of available servers and the total number of servers.
+func healthyCount(servers []*proxy.Proxy) (int, int) {
+	healthy := 0
+	for _, server := range servers {
+		if server.IsAvailable() {
+			healthy++
+		}
+	}
+	return healthy, len(servers)
+}
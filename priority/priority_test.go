@@ -0,0 +1,107 @@
+package priority
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+func newTier(t *testing.T, prefix string, states ...health.State) roundrobin.RoundRobin {
+	t.Helper()
+	servers := make([]*proxy.Proxy, len(states))
+	for i, state := range states {
+		servers[i] = proxytest.NewProxy(t, prefix+string(rune('1'+i)), state)
+	}
+	r, _ := roundrobin.New(servers...)
+	return r
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil); !errors.Is(err, ErrTiersEmpty) {
+		t.Errorf("New() error = %v, want %v", err, ErrTiersEmpty)
+	}
+
+	tier, _ := roundrobin.New()
+	if _, err := New([]roundrobin.RoundRobin{tier}, WithThreshold(0)); !errors.Is(err, ErrInvalidThreshold) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidThreshold)
+	}
+}
+
+func TestPriority_ActiveTier(t *testing.T) {
+	healthy, unhealthy := health.StateHealthy, health.StateUnhealthy
+
+	tests := []struct {
+		name  string
+		tiers [][]health.State
+		want  int
+	}{
+		{
+			name:  "primary healthy",
+			tiers: [][]health.State{{healthy, healthy, unhealthy}, {healthy}},
+			want:  0,
+		},
+		{
+			name:  "primary below threshold",
+			tiers: [][]health.State{{healthy, unhealthy, unhealthy}, {healthy}},
+			want:  1,
+		},
+		{
+			name:  "empty primary",
+			tiers: [][]health.State{{}, {healthy}},
+			want:  1,
+		},
+		{
+			name:  "no tier above threshold",
+			tiers: [][]health.State{{healthy, unhealthy, unhealthy}, {unhealthy, unhealthy}, {unhealthy}},
+			want:  0,
+		},
+		{
+			name:  "nothing available uses last resort",
+			tiers: [][]health.State{{unhealthy}, {unhealthy}, {unhealthy}},
+			want:  2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tiers := make([]roundrobin.RoundRobin, len(tt.tiers))
+			for i, states := range tt.tiers {
+				tiers[i] = newTier(t, string(rune('a'+i)), states...)
+			}
+
+			p, err := New(tiers, WithThreshold(0.6))
+			if err != nil {
+				t.Fatalf("Failed to create priority: %v", err)
+			}
+
+			if got := p.ActiveTier(); got != tt.want {
+				t.Errorf("ActiveTier() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriority_NextServer(t *testing.T) {
+	primary := newTier(t, "a", health.StateHealthy, health.StateUnhealthy, health.StateHealthy)
+	secondary := newTier(t, "b", health.StateHealthy)
+
+	p, err := New([]roundrobin.RoundRobin{primary, secondary}, WithThreshold(0.6))
+	if err != nil {
+		t.Fatalf("Failed to create priority: %v", err)
+	}
+
+	for i := 0; i < 6; i++ {
+		name := p.NextServer().GetName()
+		if name != "a1" && name != "a3" {
+			t.Fatalf("Expected an available primary server, but got %s", name)
+		}
+	}
+
+	if got := len(p.Servers()); got != 4 {
+		t.Fatalf("Expected 4 servers, but got %d", got)
+	}
+}
@@ -0,0 +1,32 @@
+package proxy
+
+import "net/http"
+
+// Balancer is implemented by the load balancing algorithms that select a Proxy,
+// such as roundrobin.RoundRobin.
+type Balancer interface {
+	// NextServer returns the next server, or nil if no server is available.
+	NextServer() *Proxy
+
+	// Servers returns a list of all servers in the load balancer.
+	Servers() []*Proxy
+}
+
+// Mirror receives a copy of the requests served by a Proxy, see WithMirror.
+type Mirror interface {
+	// Mirror is called before the request is forwarded and returns the
+	// request to forward, with its body restored if it was read.
+	// It must not wait for the mirrored request to complete.
+	Mirror(r *http.Request) *http.Request
+}
+
+// Filter returns the proxies matching the label selector, see Proxy.MatchLabels.
+func Filter(servers []*Proxy, selector map[string]string) []*Proxy {
+	var matched []*Proxy
+	for _, s := range servers {
+		if s.MatchLabels(selector) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
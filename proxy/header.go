@@ -0,0 +1,130 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// defaultUpstreamHeader is the response header holding the name of the proxy, see WithUpstreamHeader.
+const defaultUpstreamHeader = "X-Upstream"
+
+// headerConfig holds the header rewriting settings of the proxy.
+type headerConfig struct {
+	xForwarded      bool
+	forwarded       bool
+	host            string
+	originHost      bool
+	setRequest      http.Header
+	removeRequest   []string
+	setResponse     http.Header
+	removeResponse  []string
+	upstreamHeader  string
+	requestRewrite  bool
+	responseRewrite bool
+}
+
+// rewriteRequest applies the header settings to the outgoing request.
+// It is called after the default director, while req.Host is still the
+// host requested by the client.
+func (c *headerConfig) rewriteRequest(req *http.Request, origin *url.URL) {
+	proto := "http"
+	if req.TLS != nil {
+		proto = "https"
+	}
+
+	if c.xForwarded {
+		req.Header.Set("X-Forwarded-Proto", proto)
+		req.Header.Set("X-Forwarded-Host", req.Host)
+	}
+
+	if c.forwarded {
+		element := "proto=" + proto
+		if req.Host != "" {
+			element = "host=" + quote(req.Host) + ";" + element
+		}
+		if ip, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+			element = "for=" + forwardedNode(ip) + ";" + element
+		}
+		if prior := req.Header.Values("Forwarded"); len(prior) > 0 {
+			element = strings.Join(prior, ", ") + ", " + element
+		}
+		req.Header.Set("Forwarded", element)
+	}
+
+	switch {
+	case c.host != "":
+		req.Host = c.host
+	case c.originHost:
+		req.Host = origin.Host
+	}
+
+	for key, values := range c.setRequest {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	for _, key := range c.removeRequest {
+		req.Header.Del(key)
+	}
+}
+
+// rewriteResponse applies the header settings to the response headers.
+func (c *headerConfig) rewriteResponse(header http.Header, name string) {
+	for key, values := range c.setResponse {
+		header[key] = append([]string(nil), values...)
+	}
+	for _, key := range c.removeResponse {
+		header.Del(key)
+	}
+	if c.upstreamHeader != "" {
+		header.Set(c.upstreamHeader, name)
+	}
+}
+
+// forwardedNode formats the IP address as a node of the Forwarded header,
+// with IPv6 addresses in brackets and quoted as required by RFC 7239.
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// quote returns the value as a quoted string if it is not a valid token,
+// for example a host with a port.
+func quote(value string) string {
+	for _, c := range value {
+		if !isTokenChar(c) {
+			return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		}
+	}
+	return value
+}
+
+// isTokenChar reports whether c is allowed in a token as defined by RFC 7230.
+func isTokenChar(c rune) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", c)
+}
+
+// rewriteHeaders installs the header settings on the reverse proxy.
+func (p *Proxy) rewriteHeaders(origin *url.URL) {
+	c := &p.headers
+	if c.requestRewrite {
+		director := p.proxy.Director
+		p.proxy.Director = func(req *http.Request) {
+			director(req)
+			c.rewriteRequest(req, origin)
+		}
+	}
+
+	if c.responseRewrite {
+		p.proxy.ModifyResponse = func(resp *http.Response) error {
+			c.rewriteResponse(resp.Header, p.name)
+			return nil
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package health
+
+import (
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Event describes a change of the health state of a proxy origin.
+type Event struct {
+	// Name is the name of the proxy, see WithName.
+	Name string
+	// Origin is the address being checked.
+	Origin *url.URL
+	// From is the state before the change.
+	From State
+	// To is the state after the change.
+	To State
+	// Err is the error returned by the last health check, if any.
+	Err error
+	// Time is the time at which the change was detected.
+	Time time.Time
+}
+
+// subscriber is a registered receiver of health events.
+type subscriber struct {
+	id uint64
+	fn func(Event)
+}
+
+// OnStatusChange registers fn to be called every time the state changes.
+// The callbacks are invoked synchronously from the health check goroutine,
+// so they must not block. The returned function unregisters the callback.
+func (h *ProxyHealth) OnStatusChange(fn func(Event)) func() {
+	h.mu.Lock()
+	h.nextSubscriberID++
+	id := h.nextSubscriberID
+	h.subscribers = append(h.subscribers, subscriber{id: id, fn: fn})
+	h.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			h.unsubscribe(id)
+		})
+	}
+}
+
+// Subscribe returns a channel that receives an Event every time the state changes.
+// The channel has the given buffer size; events are dropped when the buffer is full.
+// The returned function unregisters the subscription and closes the channel.
+func (h *ProxyHealth) Subscribe(size int) (<-chan Event, func()) {
+	var mu sync.Mutex
+	closed := false
+	ch := make(chan Event, size)
+
+	unsubscribe := h.OnStatusChange(func(e Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	})
+
+	return ch, func() {
+		unsubscribe()
+		mu.Lock()
+		defer mu.Unlock()
+		if !closed {
+			closed = true
+			close(ch)
+		}
+	}
+}
+
+// unsubscribe removes the subscriber with the given id.
+func (h *ProxyHealth) unsubscribe(id uint64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, s := range h.subscribers {
+		if s.id == id {
+			h.subscribers = append(h.subscribers[:i:i], h.subscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify sends the event to all registered subscribers.
+func (h *ProxyHealth) notify(e Event) {
+	h.mu.Lock()
+	subscribers := h.subscribers
+	h.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.fn(e)
+	}
+}
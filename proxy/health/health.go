@@ -3,6 +3,7 @@ package health
 import (
 	"context"
 	"errors"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -10,9 +11,26 @@ import (
 	"time"
 )
 
+var (
+	// ErrInvalidPeriod is returned when the check period is not positive.
+	ErrInvalidPeriod = errors.New("health: period must be greater than zero")
+	// ErrInvalidInitialDelay is returned when the initial delay is negative.
+	ErrInvalidInitialDelay = errors.New("health: initial delay must not be negative")
+	// ErrInvalidThreshold is returned when a success or failure threshold is less than 1.
+	ErrInvalidThreshold = errors.New("health: thresholds must be at least 1")
+	// ErrInvalidJitter is returned when the jitter is negative or not shorter than the period.
+	ErrInvalidJitter = errors.New("health: jitter must be in the range [0, period)")
+	// ErrInvalidUnhealthyPeriod is returned when the unhealthy period is negative or longer than the period.
+	ErrInvalidUnhealthyPeriod = errors.New("health: unhealthy period must be in the range [0, period]")
+	// ErrInvalidHistorySize is returned when the history size is negative.
+	ErrInvalidHistorySize = errors.New("health: history size must not be negative")
+	// ErrInvalidState is returned when the initial state is not a defined State.
+	ErrInvalidState = errors.New("health: invalid initial state")
+)
+
 const (
-	// Default to 10 seconds. The minimum value is 1
-	defaultPeriod = 10
+	// Default to 10 seconds. Must be greater than zero
+	defaultPeriod = 10 * time.Second
 	// If the value of period is greater than initialDelay then the initialDelay will be ignored
 	// Defaults to 0 seconds. Minimum value is 0.
 	defaultInitialDelay = 0
@@ -20,54 +38,107 @@ const (
 	defaultSuccessThreshold = 1
 	// Default failure threshold
 	defaultFailureThreshold = 3
+	// Default number of check results kept in the history
+	defaultHistorySize = 10
 )
 
 type Check func(addr *url.URL) error
 
-func New(origin *url.URL, opts ...Opts) *ProxyHealth {
+// New creates a new ProxyHealth for the origin and starts checking it.
+// It returns an error if the options form an invalid configuration.
+func New(origin *url.URL, opts ...Opts) (*ProxyHealth, error) {
 	h := &ProxyHealth{
-		origin:              origin,
-		check:               defaultHTTPCheck,
-		periodSeconds:       defaultPeriod,
-		initialDelaySeconds: defaultInitialDelay,
-		successThreshold:    defaultSuccessThreshold,
-		failureThreshold:    defaultFailureThreshold,
-		successCount:        0,
-		failureCount:        0,
-		cancel:              make(chan struct{}),
+		origin:           origin,
+		check:            defaultCheck(origin),
+		period:           defaultPeriod,
+		initialDelay:     defaultInitialDelay,
+		successThreshold: defaultSuccessThreshold,
+		failureThreshold: defaultFailureThreshold,
+		successCount:     0,
+		failureCount:     0,
+		historySize:      defaultHistorySize,
 	}
 
 	for _, opt := range opts {
 		opt(h)
 	}
 
-	h.run()
-	return h
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+
+	h.Start()
+	return h, nil
+}
+
+// validate checks the configuration of the health check.
+func (h *ProxyHealth) validate() error {
+	switch {
+	case h.period <= 0:
+		return ErrInvalidPeriod
+	case h.initialDelay < 0:
+		return ErrInvalidInitialDelay
+	case h.successThreshold < 1, h.failureThreshold < 1:
+		return ErrInvalidThreshold
+	case h.jitter < 0, h.jitter >= h.period:
+		return ErrInvalidJitter
+	case h.unhealthyPeriod < 0, h.unhealthyPeriod > h.period:
+		return ErrInvalidUnhealthyPeriod
+	case h.historySize < 0:
+		return ErrInvalidHistorySize
+	case !h.state.valid():
+		return ErrInvalidState
+	}
+	return nil
 }
 
 type ProxyHealth struct {
 	origin *url.URL
+	name   string
 
-	mu                  sync.Mutex
-	check               Check
-	periodSeconds       int
-	initialDelaySeconds int
-	successThreshold    int
-	failureThreshold    int
-	successCount        int
-	failureCount        int
-	cancel              chan struct{}
-	isAvailable         bool
-	errors              error
+	mu               sync.Mutex
+	runMu            sync.Mutex
+	check            Check
+	period           time.Duration
+	unhealthyPeriod  time.Duration
+	initialDelay     time.Duration
+	jitter           time.Duration
+	successThreshold int
+	failureThreshold int
+	successCount     int
+	failureCount     int
+	cancel           chan struct{}
+	state            State
+	lastError        error
+	lastCheck        time.Time
+	lastTransition   time.Time
+	history          []Result
+	historySize      int
+	historyNext      int
+	subscribers      []subscriber
+	nextSubscriberID uint64
+	scheduler        *Scheduler
+	probeKey         string
 }
 
 // checkHealth checks the health of the proxy origin.
+// Subscribers are notified if the state changed.
+// The check itself runs without holding the lock, so that readers
+// are never blocked by a slow origin.
 func (h *ProxyHealth) checkHealth() error {
-	h.mu.Lock()
-	defer h.mu.Unlock()
-
+	start := time.Now()
 	err := h.check(h.origin)
+	h.apply(err, start, time.Now())
+	return err
+}
 
+// apply records the result of a check that started at start and completed at now.
+func (h *ProxyHealth) apply(err error, start, now time.Time) {
+	h.mu.Lock()
+	previous := h.state
+
+	// successCount and failureCount hold the number of consecutive
+	// successful and failed checks.
 	if err == nil {
 		h.successCount++
 		h.failureCount = 0
@@ -77,62 +148,175 @@ func (h *ProxyHealth) checkHealth() error {
 	}
 
 	if h.successCount >= h.successThreshold {
-		h.isAvailable = true
-		h.successCount = 0
+		h.state = StateHealthy
 	}
 
 	if h.failureCount >= h.failureThreshold {
-		h.isAvailable = false
-		h.failureCount = 0
+		h.state = StateUnhealthy
 	}
 
-	return err
+	h.lastError = err
+	h.lastCheck = now
+	h.record(Result{Time: now, Latency: now.Sub(start), Err: err})
+	current := h.state
+	name := h.name
+	if previous != current {
+		h.lastTransition = now
+	}
+	h.mu.Unlock()
+
+	if previous != current {
+		h.notify(Event{
+			Name:   name,
+			Origin: h.origin,
+			From:   previous,
+			To:     current,
+			Err:    err,
+			Time:   now,
+		})
+	}
 }
 
-func (h *ProxyHealth) run() {
+// Start starts the periodic health check in a background goroutine,
+// or registers it with the scheduler set by WithScheduler.
+// It is a no-op if the health check is already running, and it can be
+// called again after Stop to resume checking.
+func (h *ProxyHealth) Start() {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+
+	if h.cancel != nil {
+		return
+	}
+
+	h.cancel = make(chan struct{})
+	if h.scheduler != nil {
+		h.scheduler.add(h)
+		return
+	}
+	go h.run(h.cancel)
+}
+
+// Stop stops the periodic health check. The last known availability is kept.
+// It is safe to call Stop multiple times.
+func (h *ProxyHealth) Stop() {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+
+	if h.cancel == nil {
+		return
+	}
+
+	close(h.cancel)
+	h.cancel = nil
+	if h.scheduler != nil {
+		h.scheduler.remove(h)
+	}
+}
+
+func (h *ProxyHealth) run(cancel <-chan struct{}) {
 	// initial delay
-	if h.initialDelaySeconds > 0 {
+	if delay := h.firstInterval(); delay > 0 {
 		select {
-		case <-time.After(time.Duration(h.initialDelaySeconds) * time.Second):
-		case <-h.cancel:
+		case <-time.After(delay):
+		case <-cancel:
 			return
 		}
 	}
 
-	go func() {
-		for {
-			select {
-			case <-h.cancel:
-				return
-			default:
-			}
+	for {
+		select {
+		case <-cancel:
+			return
+		default:
+		}
 
-			h.errors = h.checkHealth()
+		_ = h.checkHealth()
 
-			select {
-			case <-time.After(time.Duration(h.periodSeconds) * time.Second):
-			case <-h.cancel:
-				return
-			}
+		select {
+		case <-time.After(h.nextInterval()):
+		case <-cancel:
+			return
 		}
-	}()
+	}
 }
 
-// stop stops the currently rinning check func.
-func (h *ProxyHealth) stop() {
-	if h.cancel == nil {
-		return
+// firstInterval returns the time to wait before the first check.
+// The jitter is added to the initial delay as well, so that the origins
+// added at the same time are not checked at the same time.
+func (h *ProxyHealth) firstInterval() time.Duration {
+	return h.initialDelay + h.randomJitter()
+}
+
+// randomJitter returns a random duration in the range [0, jitter).
+func (h *ProxyHealth) randomJitter() time.Duration {
+	if h.jitter <= 0 {
+		return 0
 	}
+	return time.Duration(rand.Int63n(int64(h.jitter)))
+}
 
-	close(h.cancel)
-	h.cancel = nil
+// nextInterval returns the time to wait before the next check.
+// The unhealthy period is used while the origin is not healthy,
+// and a random jitter is added to spread the checks of many origins.
+func (h *ProxyHealth) nextInterval() time.Duration {
+	h.mu.Lock()
+	interval := h.period
+	if h.state != StateHealthy && h.unhealthyPeriod > 0 {
+		interval = h.unhealthyPeriod
+	}
+	h.mu.Unlock()
+
+	return interval + h.randomJitter()
+}
+
+// Name returns the name reported in health events, see WithName.
+func (h *ProxyHealth) Name() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.name
+}
+
+// SetDefaultName sets the name reported in health events if none was set with WithName.
+// It is used to name a health check once it is attached to a proxy.
+func (h *ProxyHealth) SetDefaultName(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.name == "" {
+		h.name = name
+	}
 }
 
 // IsAvailable returns whether the proxy origin was successfully connected at the last check time.
 func (h *ProxyHealth) IsAvailable() bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	return h.isAvailable
+	return h.state == StateHealthy
+}
+
+// State returns the current health state of the proxy origin.
+func (h *ProxyHealth) State() State {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.state
+}
+
+// defaultCheck returns the health check used when none is set with WithCheck.
+// UDP origins do not speak HTTP and are not checked: they become healthy
+// after the first check.
+func defaultCheck(origin *url.URL) Check {
+	if origin != nil {
+		switch origin.Scheme {
+		case "udp", "udp4", "udp6":
+			return noCheck
+		}
+	}
+	return defaultHTTPCheck
+}
+
+// noCheck is a health check function that always succeeds.
+func noCheck(*url.URL) error {
+	return nil
 }
 
 // defaultHTTPCheck is a default health check function that checks
@@ -168,11 +352,17 @@ func defaultHTTPCheck(addr *url.URL) error {
 // defaultTCPCheck is a default health check function that checks
 // if the TCP connection to the address is successful.
 func defaultTCPCheck(addr *url.URL) bool {
+	return TCPCheck(addr) == nil
+}
+
+// TCPCheck is a health check function that checks if a TCP connection
+// to the address host can be established, for origins that do not speak HTTP.
+func TCPCheck(addr *url.URL) error {
 	conn, err := net.DialTimeout("tcp", addr.Host, 5*time.Second)
 	if err != nil {
-		return false
+		return err
 	}
-	return conn.Close() == nil
+	return conn.Close()
 }
 
 // defaultDNSCheck is a default health check function that checks
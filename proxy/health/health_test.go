@@ -2,9 +2,11 @@ package health
 
 import (
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 )
@@ -20,12 +22,15 @@ func TestProxyHealth_IsAvailable(t *testing.T) {
 		return nil
 	}
 
-	h := New(
+	h, err := New(
 		origin,
 		WithCheck(mockCheck),
 		WithPeriodSeconds(1),
 	)
-	defer h.stop()
+	if err != nil {
+		t.Fatalf("Failed to create health check: %v", err)
+	}
+	defer h.Stop()
 
 	time.Sleep(100 * time.Millisecond)
 
@@ -241,13 +246,15 @@ func TestProxyHealth_checkHealth(t *testing.T) {
 				failureThreshold: tt.failureThreshold,
 				cancel:           make(chan struct{}),
 				failureCount:     tt.failureCount,
-				isAvailable:      tt.isAvailable,
+			}
+			if tt.isAvailable {
+				h.state = StateHealthy
 			}
 
 			err := h.checkHealth()
 
-			if h.isAvailable != tt.wantAvailable {
-				t.Errorf("isAvailable = %v, want %v", h.isAvailable, tt.wantAvailable)
+			if h.IsAvailable() != tt.wantAvailable {
+				t.Errorf("isAvailable = %v, want %v", h.IsAvailable(), tt.wantAvailable)
 			}
 
 			if err != nil && !errors.Is(err, tt.err) {
@@ -256,3 +263,532 @@ func TestProxyHealth_checkHealth(t *testing.T) {
 		})
 	}
 }
+
+func TestProxyHealth_StartStop(t *testing.T) {
+	origin, _ := url.Parse("http://example.com")
+
+	var mu sync.Mutex
+	count := 0
+	mockCheck := func(addr *url.URL) error {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		return nil
+	}
+
+	h, err := New(
+		origin,
+		WithCheck(mockCheck),
+		WithPeriodSeconds(1),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create health check: %v", err)
+	}
+
+	// Start is a no-op while the check is already running.
+	h.Start()
+	time.Sleep(100 * time.Millisecond)
+
+	h.Stop()
+	h.Stop()
+
+	mu.Lock()
+	got := count
+	mu.Unlock()
+	if got != 1 {
+		t.Fatalf("Expected 1 check before Stop, but got %d", got)
+	}
+
+	// No more checks are executed after Stop.
+	time.Sleep(1200 * time.Millisecond)
+	mu.Lock()
+	got = count
+	mu.Unlock()
+	if got != 1 {
+		t.Fatalf("Expected no checks after Stop, but got %d", got)
+	}
+
+	// Start resumes the health check.
+	h.Start()
+	defer h.Stop()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	got = count
+	mu.Unlock()
+	if got != 2 {
+		t.Fatalf("Expected 2 checks after restart, but got %d", got)
+	}
+}
+
+func TestProxyHealth_StatusChangeEvents(t *testing.T) {
+	healthError := errors.New("health check failed")
+	origin, _ := url.Parse("http://example.com")
+
+	var checkErr error
+	h := &ProxyHealth{
+		origin:           origin,
+		name:             "s1",
+		check:            func(addr *url.URL) error { return checkErr },
+		successThreshold: 1,
+		failureThreshold: 1,
+	}
+
+	var events []Event
+	unregister := h.OnStatusChange(func(e Event) {
+		events = append(events, e)
+	})
+	ch, unsubscribe := h.Subscribe(10)
+
+	_ = h.checkHealth()
+	_ = h.checkHealth()
+	checkErr = healthError
+	_ = h.checkHealth()
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, but got %d", len(events))
+	}
+	if events[0].Name != "s1" || events[0].From != StateUnknown || events[0].To != StateHealthy {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[1].From != StateHealthy || events[1].To != StateUnhealthy || !errors.Is(events[1].Err, healthError) {
+		t.Errorf("unexpected second event: %+v", events[1])
+	}
+
+	for i := 0; i < 2; i++ {
+		e := <-ch
+		if e.To != events[i].To {
+			t.Errorf("channel event %d: To = %v, want %v", i, e.To, events[i].To)
+		}
+	}
+
+	unregister()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Fatalf("Expected channel to be closed after unsubscribe")
+	}
+
+	checkErr = nil
+	_ = h.checkHealth()
+	if len(events) != 2 {
+		t.Fatalf("Expected no events after unregister, but got %d", len(events))
+	}
+}
+
+func TestProxyHealth_Status(t *testing.T) {
+	healthError := errors.New("health check failed")
+	origin, _ := url.Parse("http://example.com")
+
+	results := []error{nil, nil, healthError, healthError, healthError}
+	calls := 0
+	h := &ProxyHealth{
+		origin: origin,
+		check: func(addr *url.URL) error {
+			err := results[calls]
+			calls++
+			return err
+		},
+		successThreshold: defaultSuccessThreshold,
+		failureThreshold: defaultFailureThreshold,
+		historySize:      3,
+	}
+
+	for range results {
+		_ = h.checkHealth()
+	}
+
+	status := h.Status()
+	if status.Available || status.State != StateUnhealthy {
+		t.Errorf("Available = %v, State = %v, want false, unhealthy", status.Available, status.State)
+	}
+	if !errors.Is(status.LastError, healthError) {
+		t.Errorf("LastError = %v, want %v", status.LastError, healthError)
+	}
+	if status.ConsecutiveFailures != 3 || status.ConsecutiveSuccesses != 0 {
+		t.Errorf("consecutive successes/failures = %d/%d, want 0/3",
+			status.ConsecutiveSuccesses, status.ConsecutiveFailures)
+	}
+	if status.LastCheck.IsZero() || status.LastTransition.IsZero() {
+		t.Errorf("expected LastCheck and LastTransition to be set")
+	}
+	if len(status.History) != 3 {
+		t.Fatalf("Expected 3 history entries, but got %d", len(status.History))
+	}
+	for i, r := range status.History {
+		if !errors.Is(r.Err, healthError) {
+			t.Errorf("History[%d].Err = %v, want %v", i, r.Err, healthError)
+		}
+		if i > 0 && r.Time.Before(status.History[i-1].Time) {
+			t.Errorf("History is not ordered oldest first")
+		}
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	origin, _ := url.Parse("http://example.com")
+	noop := WithCheck(func(addr *url.URL) error { return nil })
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{
+			name: "valid sub-second options",
+			opts: []Opts{
+				WithPeriod(500 * time.Millisecond),
+				WithUnhealthyPeriod(100 * time.Millisecond),
+				WithInitialDelay(10 * time.Millisecond),
+				WithJitter(50 * time.Millisecond),
+				WithSuccessThreshold(2),
+				WithFailureThreshold(5),
+			},
+			err: nil,
+		},
+		{
+			name: "zero period",
+			opts: []Opts{WithPeriod(0)},
+			err:  ErrInvalidPeriod,
+		},
+		{
+			name: "negative initial delay",
+			opts: []Opts{WithInitialDelay(-time.Second)},
+			err:  ErrInvalidInitialDelay,
+		},
+		{
+			name: "zero success threshold",
+			opts: []Opts{WithSuccessThreshold(0)},
+			err:  ErrInvalidThreshold,
+		},
+		{
+			name: "zero failure threshold",
+			opts: []Opts{WithFailureThreshold(0)},
+			err:  ErrInvalidThreshold,
+		},
+		{
+			name: "jitter not shorter than period",
+			opts: []Opts{WithPeriod(time.Second), WithJitter(time.Second)},
+			err:  ErrInvalidJitter,
+		},
+		{
+			name: "unhealthy period longer than period",
+			opts: []Opts{WithPeriod(time.Second), WithUnhealthyPeriod(2 * time.Second)},
+			err:  ErrInvalidUnhealthyPeriod,
+		},
+		{
+			name: "negative history size",
+			opts: []Opts{WithHistorySize(-1)},
+			err:  ErrInvalidHistorySize,
+		},
+		{
+			name: "invalid initial state",
+			opts: []Opts{WithInitialState(State(42))},
+			err:  ErrInvalidState,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := New(origin, append([]Opts{noop}, tt.opts...)...)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("New() error = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				h.Stop()
+			}
+		})
+	}
+}
+
+func TestProxyHealth_UnhealthyPeriod(t *testing.T) {
+	origin, _ := url.Parse("http://example.com")
+
+	var mu sync.Mutex
+	count := 0
+	h, err := New(
+		origin,
+		WithCheck(func(addr *url.URL) error {
+			mu.Lock()
+			count++
+			mu.Unlock()
+			return errors.New("health check failed")
+		}),
+		WithPeriod(time.Hour),
+		WithUnhealthyPeriod(20*time.Millisecond),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create health check: %v", err)
+	}
+	defer h.Stop()
+
+	time.Sleep(200 * time.Millisecond)
+
+	mu.Lock()
+	got := count
+	mu.Unlock()
+	if got < 3 {
+		t.Fatalf("Expected checks to run with the unhealthy period, but got %d checks", got)
+	}
+}
+
+func TestProxyHealth_InitialJitter(t *testing.T) {
+	h := &ProxyHealth{
+		period:       time.Second,
+		initialDelay: 100 * time.Millisecond,
+		jitter:       500 * time.Millisecond,
+	}
+
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 20; i++ {
+		d := h.firstInterval()
+		if d < h.initialDelay || d >= h.initialDelay+h.jitter {
+			t.Fatalf("Expected the first interval in [%v, %v), but got %v", h.initialDelay, h.initialDelay+h.jitter, d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 2 {
+		t.Fatalf("Expected the jitter to spread the initial delay, but got %v", seen)
+	}
+
+	h.jitter = 0
+	if d := h.firstInterval(); d != h.initialDelay {
+		t.Fatalf("Expected the first interval %v without jitter, but got %v", h.initialDelay, d)
+	}
+}
+
+func TestProxyHealth_InitialState(t *testing.T) {
+	origin, _ := url.Parse("http://example.com")
+
+	tests := []struct {
+		name      string
+		state     State
+		available bool
+	}{
+		{name: "default", state: StateUnknown, available: false},
+		{name: "healthy", state: StateHealthy, available: true},
+		{name: "unhealthy", state: StateUnhealthy, available: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []Opts{
+				WithCheck(func(addr *url.URL) error { return nil }),
+				WithInitialDelay(time.Hour),
+			}
+			if tt.state != StateUnknown {
+				opts = append(opts, WithInitialState(tt.state))
+			}
+
+			h, err := New(origin, opts...)
+			if err != nil {
+				t.Fatalf("Failed to create health check: %v", err)
+			}
+			defer h.Stop()
+
+			if h.State() != tt.state {
+				t.Errorf("State() = %v, want %v", h.State(), tt.state)
+			}
+			if h.IsAvailable() != tt.available {
+				t.Errorf("IsAvailable() = %v, want %v", h.IsAvailable(), tt.available)
+			}
+		})
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	if _, err := NewScheduler(0); !errors.Is(err, ErrInvalidWorkers) {
+		t.Fatalf("NewScheduler(0) error = %v, want %v", err, ErrInvalidWorkers)
+	}
+
+	s, err := NewScheduler(1)
+	if err != nil {
+		t.Fatalf("Failed to create scheduler: %v", err)
+	}
+	defer s.Close()
+
+	var mu sync.Mutex
+	probes := map[string]int{}
+	running, maxRunning := 0, 0
+	check := func(addr *url.URL) error {
+		mu.Lock()
+		probes[addr.String()]++
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+
+	newHealth := func(origin string) *ProxyHealth {
+		addr, _ := url.Parse(origin)
+		h, err := New(addr, WithCheck(check), WithPeriod(time.Hour), WithInitialDelay(20*time.Millisecond),
+			WithScheduler(s), WithProbeKey(origin))
+		if err != nil {
+			t.Fatalf("Failed to create health check: %v", err)
+		}
+		return h
+	}
+
+	// s1 and s2 share the same probe key and must be probed once.
+	s1 := newHealth("http://a.example.com")
+	s2 := newHealth("http://a.example.com")
+	s3 := newHealth("http://b.example.com")
+	s4 := newHealth("http://c.example.com")
+	defer s4.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	if probes["http://a.example.com"] != 1 {
+		t.Errorf("Expected 1 probe for the shared origin, but got %d", probes["http://a.example.com"])
+	}
+	if len(probes) != 3 {
+		t.Errorf("Expected 3 probed origins, but got %d", len(probes))
+	}
+	if maxRunning != 1 {
+		t.Errorf("Expected at most 1 concurrent probe, but got %d", maxRunning)
+	}
+	mu.Unlock()
+
+	for _, h := range []*ProxyHealth{s1, s2, s3, s4} {
+		if !h.IsAvailable() {
+			t.Errorf("Expected %s to be available", h.origin)
+		}
+	}
+
+	// Stopping all registrations of an origin removes it from the scheduler.
+	s1.Stop()
+	s2.Stop()
+	s3.Stop()
+
+	s.mu.Lock()
+	if len(s.entries) != 1 || s.queue.Len() != 1 {
+		t.Errorf("Expected 1 scheduled origin, but got %d entries and %d queued", len(s.entries), s.queue.Len())
+	}
+	s.mu.Unlock()
+}
+
+func TestScheduler_ProbeKey(t *testing.T) {
+	s, _ := NewScheduler(2)
+	defer s.Close()
+
+	// Closures created by the same function literal capture different paths.
+	var mu sync.Mutex
+	probes := map[string]int{}
+	pathCheck := func(path string) Check {
+		return func(*url.URL) error {
+			mu.Lock()
+			defer mu.Unlock()
+			probes[path]++
+			if path == "/b" {
+				return errors.New("not found")
+			}
+			return nil
+		}
+	}
+
+	addr, _ := url.Parse("http://a.example.com")
+	a, _ := New(addr, WithCheck(pathCheck("/a")), WithPeriod(time.Hour), WithFailureThreshold(1), WithScheduler(s))
+	b, _ := New(addr, WithCheck(pathCheck("/b")), WithPeriod(time.Hour), WithFailureThreshold(1), WithScheduler(s))
+	defer a.Stop()
+	defer b.Stop()
+
+	deadline := time.Now().Add(time.Second)
+	for a.State() == StateUnknown || b.State() == StateUnknown {
+		if time.Now().After(deadline) {
+			t.Fatalf("States = %s and %s, want both checked", a.State(), b.State())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if a.State() != StateHealthy || b.State() != StateUnhealthy {
+		t.Errorf("States = %s and %s, want %s and %s", a.State(), b.State(), StateHealthy, StateUnhealthy)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if probes["/a"] != 1 || probes["/b"] != 1 {
+		t.Errorf("Probes = %v, want one per path", probes)
+	}
+}
+
+func TestTCPCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	open := &url.URL{Scheme: "tcp", Host: l.Addr().String()}
+
+	if err := TCPCheck(open); err != nil {
+		t.Errorf("TCPCheck() error = %v, want nil", err)
+	}
+
+	_ = l.Close()
+	if err := TCPCheck(open); err == nil {
+		t.Error("TCPCheck() error = nil, want an error for a closed port")
+	}
+}
+
+func TestScheduler_Join(t *testing.T) {
+	s, _ := NewScheduler(2)
+	defer s.Close()
+
+	addr, _ := url.Parse("http://a.example.com")
+	var httpProbes, tcpProbes int
+	var mu sync.Mutex
+	httpCheck := func(*url.URL) error {
+		mu.Lock()
+		defer mu.Unlock()
+		httpProbes++
+		return nil
+	}
+	tcpCheck := func(*url.URL) error {
+		mu.Lock()
+		defer mu.Unlock()
+		tcpProbes++
+		return errors.New("connection refused")
+	}
+
+	newHealth := func(check Check, opts ...Opts) *ProxyHealth {
+		opts = append(opts, WithCheck(check), WithPeriod(time.Hour), WithFailureThreshold(1), WithScheduler(s))
+		h, err := New(addr, opts...)
+		if err != nil {
+			t.Fatalf("Failed to create health check: %v", err)
+		}
+		t.Cleanup(h.Stop)
+		return h
+	}
+
+	waitState := func(h *ProxyHealth, want State) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for h.State() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("State() = %s, want %s", h.State(), want)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	first := newHealth(httpCheck, WithProbeKey("http"))
+	waitState(first, StateHealthy)
+
+	// A member joining an entry probed an hour from now gets its result right away.
+	joined := newHealth(httpCheck, WithProbeKey("http"))
+	waitState(joined, StateHealthy)
+
+	// A health check without a probe key is probed on its own.
+	other := newHealth(tcpCheck)
+	waitState(other, StateUnhealthy)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if httpProbes != 2 || tcpProbes != 1 {
+		t.Errorf("Probes = %d HTTP and %d TCP, want 2 and 1", httpProbes, tcpProbes)
+	}
+}
@@ -1,5 +1,7 @@
 package health
 
+import "time"
+
 type Opts func(*ProxyHealth)
 
 // WithCheck sets the health check function for the proxy.
@@ -11,14 +13,98 @@ func WithCheck(check Check) Opts {
 
 // WithPeriodSeconds sets the period for the health check.
 func WithPeriodSeconds(periodSeconds int) Opts {
-	return func(h *ProxyHealth) {
-		h.periodSeconds = periodSeconds
-	}
+	return WithPeriod(time.Duration(periodSeconds) * time.Second)
 }
 
 // WithInitialDelaySeconds sets the initial delay for the health check.
 func WithInitialDelaySeconds(initialDelaySeconds int) Opts {
+	return WithInitialDelay(time.Duration(initialDelaySeconds) * time.Second)
+}
+
+// WithPeriod sets the period for the health check.
+func WithPeriod(period time.Duration) Opts {
+	return func(h *ProxyHealth) {
+		h.period = period
+	}
+}
+
+// WithUnhealthyPeriod sets the period for the health check while the origin is not available.
+// It must not be longer than the period. Defaults to the period.
+func WithUnhealthyPeriod(period time.Duration) Opts {
+	return func(h *ProxyHealth) {
+		h.unhealthyPeriod = period
+	}
+}
+
+// WithInitialDelay sets the initial delay for the health check.
+func WithInitialDelay(initialDelay time.Duration) Opts {
+	return func(h *ProxyHealth) {
+		h.initialDelay = initialDelay
+	}
+}
+
+// WithJitter adds a random duration in the range [0, jitter) to the initial delay
+// and to every period, so that the checks of many origins do not fire at the same time.
+func WithJitter(jitter time.Duration) Opts {
+	return func(h *ProxyHealth) {
+		h.jitter = jitter
+	}
+}
+
+// WithSuccessThreshold sets the number of consecutive successful checks
+// required to consider the origin available.
+func WithSuccessThreshold(threshold int) Opts {
+	return func(h *ProxyHealth) {
+		h.successThreshold = threshold
+	}
+}
+
+// WithFailureThreshold sets the number of consecutive failed checks
+// required to consider the origin unavailable.
+func WithFailureThreshold(threshold int) Opts {
+	return func(h *ProxyHealth) {
+		h.failureThreshold = threshold
+	}
+}
+
+// WithName sets the name reported in health events.
+func WithName(name string) Opts {
+	return func(h *ProxyHealth) {
+		h.name = name
+	}
+}
+
+// WithHistorySize sets the number of recent check results kept for Status.
+func WithHistorySize(size int) Opts {
+	return func(h *ProxyHealth) {
+		h.historySize = size
+	}
+}
+
+// WithInitialState sets the state of the origin before the first checks complete.
+// Defaults to StateUnknown, which is not considered available.
+func WithInitialState(state State) Opts {
+	return func(h *ProxyHealth) {
+		h.state = state
+	}
+}
+
+// WithScheduler runs the health check on the shared scheduler instead of
+// a dedicated goroutine. Health checks registered with the same scheduler
+// and the same probe key share a single probe, see WithProbeKey.
+func WithScheduler(s *Scheduler) Opts {
+	return func(h *ProxyHealth) {
+		h.scheduler = s
+	}
+}
+
+// WithProbeKey sets the key identifying the probe of the health check on
+// its scheduler. The health checks with the same key share a single probe,
+// run with the origin and check function of one of them, so they must probe
+// the same origin the same way. Without a key, the health check is probed
+// on its own.
+func WithProbeKey(key string) Opts {
 	return func(h *ProxyHealth) {
-		h.initialDelaySeconds = initialDelaySeconds
+		h.probeKey = key
 	}
 }
@@ -0,0 +1,294 @@
+package health
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrInvalidWorkers is returned when a scheduler is created without workers.
+var ErrInvalidWorkers = errors.New("health: scheduler needs at least one worker")
+
+// Scheduler runs the health checks of many ProxyHealth instances on a
+// bounded pool of workers, instead of one goroutine and timer per origin.
+// The checks are ordered by their next run time in a min-heap.
+//
+// Health checks registered with the same probe key, see WithProbeKey, are
+// de-duplicated: a single probe runs at the shortest interval among the
+// registrations, and the result is applied to all of them with their own
+// thresholds. The other health checks are probed on their own.
+type Scheduler struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+	queue   entryQueue
+
+	work    chan *entry
+	wake    chan struct{}
+	done    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+}
+
+// entry is a scheduled probe of a single origin.
+type entry struct {
+	key     string
+	members []*ProxyHealth
+	next    time.Time
+	// index is the position in the queue, -1 while the probe is running.
+	index int
+	// rerun is the time of the next probe requested by a member
+	// that joined while the probe was running, zero if none.
+	rerun time.Time
+}
+
+// entryKey identifies the probe of the health check: its probe key if set,
+// the registration itself otherwise.
+func entryKey(h *ProxyHealth) string {
+	if h.probeKey != "" {
+		return "key " + h.probeKey
+	}
+	return fmt.Sprintf("health %p", h)
+}
+
+// NewScheduler creates a scheduler that runs at most workers health checks concurrently.
+func NewScheduler(workers int) (*Scheduler, error) {
+	if workers < 1 {
+		return nil, ErrInvalidWorkers
+	}
+
+	s := &Scheduler{
+		entries: make(map[string]*entry),
+		work:    make(chan *entry),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(workers + 1)
+	go s.loop()
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+
+	return s, nil
+}
+
+// Close stops the scheduler and waits for the running checks to complete.
+// The registered health checks are no longer executed.
+func (s *Scheduler) Close() {
+	s.closing.Do(func() {
+		close(s.done)
+	})
+	s.wg.Wait()
+}
+
+// add registers the health check with the scheduler.
+func (s *Scheduler) add(h *ProxyHealth) {
+	key := entryKey(h)
+	due := time.Now().Add(h.firstInterval())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.members = append(e.members, h)
+		// Probe early enough for the new member to get its first result
+		// after its own initial delay rather than a full period.
+		switch {
+		case e.index < 0:
+			if e.rerun.IsZero() || due.Before(e.rerun) {
+				e.rerun = due
+			}
+		case due.Before(e.next):
+			e.next = due
+			heap.Fix(&s.queue, e.index)
+			s.notify()
+		}
+		return
+	}
+
+	e := &entry{
+		key:     key,
+		members: []*ProxyHealth{h},
+		next:    due,
+	}
+	s.entries[key] = e
+	heap.Push(&s.queue, e)
+	s.notify()
+}
+
+// remove unregisters the health check from the scheduler.
+func (s *Scheduler) remove(h *ProxyHealth) {
+	key := entryKey(h)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return
+	}
+
+	for i, m := range e.members {
+		if m == h {
+			e.members = append(e.members[:i:i], e.members[i+1:]...)
+			break
+		}
+	}
+
+	if len(e.members) > 0 {
+		return
+	}
+
+	delete(s.entries, key)
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+}
+
+// notify wakes up the scheduling loop. The caller must hold the lock.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop dispatches the due entries to the workers.
+func (s *Scheduler) loop() {
+	defer s.wg.Done()
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		now := time.Now()
+		var due []*entry
+		wait := time.Hour
+
+		s.mu.Lock()
+		for s.queue.Len() > 0 && !s.queue[0].next.After(now) {
+			due = append(due, heap.Pop(&s.queue).(*entry))
+		}
+		if s.queue.Len() > 0 {
+			wait = s.queue[0].next.Sub(now)
+		}
+		s.mu.Unlock()
+
+		// Sending blocks until a worker is free, which bounds the concurrency.
+		for _, e := range due {
+			select {
+			case s.work <- e:
+			case <-s.done:
+				return
+			}
+		}
+		if len(due) > 0 {
+			continue
+		}
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// worker probes the origins dispatched by the loop.
+func (s *Scheduler) worker() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case e := <-s.work:
+			s.probe(e)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// probe checks the origin of the entry with the check of its first member,
+// applies the result to all registered health checks and schedules the next probe.
+func (s *Scheduler) probe(e *entry) {
+	s.mu.Lock()
+	members := e.members
+	s.mu.Unlock()
+
+	if len(members) == 0 {
+		return
+	}
+
+	start := time.Now()
+	err := members[0].check(members[0].origin)
+	now := time.Now()
+
+	for _, h := range members {
+		h.apply(err, start, now)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The entry was removed while it was probed.
+	if s.entries[e.key] != e {
+		return
+	}
+
+	interval := e.members[0].nextInterval()
+	for _, h := range e.members[1:] {
+		if d := h.nextInterval(); d < interval {
+			interval = d
+		}
+	}
+
+	e.next = now.Add(interval)
+	if !e.rerun.IsZero() {
+		if e.rerun.Before(e.next) {
+			e.next = e.rerun
+		}
+		e.rerun = time.Time{}
+	}
+	heap.Push(&s.queue, e)
+	s.notify()
+}
+
+// entryQueue is a min-heap of entries ordered by their next run time.
+type entryQueue []*entry
+
+func (q entryQueue) Len() int { return len(q) }
+
+func (q entryQueue) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+
+func (q entryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *entryQueue) Push(x any) {
+	e := x.(*entry)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *entryQueue) Pop() any {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
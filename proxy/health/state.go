@@ -0,0 +1,32 @@
+package health
+
+// State is the health state of a proxy origin.
+type State int
+
+const (
+	// StateUnknown means the origin has not been checked often enough to decide.
+	StateUnknown State = iota
+	// StateHealthy means the origin passed the success threshold.
+	StateHealthy
+	// StateUnhealthy means the origin reached the failure threshold.
+	StateUnhealthy
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case StateUnknown:
+		return "unknown"
+	case StateHealthy:
+		return "healthy"
+	case StateUnhealthy:
+		return "unhealthy"
+	default:
+		return "invalid"
+	}
+}
+
+// valid reports whether s is one of the defined states.
+func (s State) valid() bool {
+	return s >= StateUnknown && s <= StateUnhealthy
+}
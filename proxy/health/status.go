@@ -0,0 +1,75 @@
+package health
+
+import "time"
+
+// Result is the outcome of a single health check.
+type Result struct {
+	// Time is the time at which the check completed.
+	Time time.Time
+	// Latency is the duration of the check.
+	Latency time.Duration
+	// Err is the error returned by the check, nil on success.
+	Err error
+}
+
+// Status is a point-in-time snapshot of the health of a proxy origin.
+type Status struct {
+	// Available reports whether the origin is considered available.
+	Available bool
+	// State is the current health state.
+	State State
+	// LastCheck is the time of the last completed check.
+	LastCheck time.Time
+	// LastError is the error returned by the last check, nil on success.
+	LastError error
+	// ConsecutiveSuccesses is the number of consecutive successful checks.
+	ConsecutiveSuccesses int
+	// ConsecutiveFailures is the number of consecutive failed checks.
+	ConsecutiveFailures int
+	// LastTransition is the time at which the state last changed.
+	LastTransition time.Time
+	// History holds the most recent check results, oldest first.
+	History []Result
+}
+
+// Status returns a snapshot of the health of the proxy origin.
+// It is safe to call Status concurrently with running health checks.
+func (h *ProxyHealth) Status() Status {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	history := make([]Result, 0, len(h.history))
+	if len(h.history) < h.historySize {
+		history = append(history, h.history...)
+	} else {
+		history = append(history, h.history[h.historyNext:]...)
+		history = append(history, h.history[:h.historyNext]...)
+	}
+
+	return Status{
+		Available:            h.state == StateHealthy,
+		State:                h.state,
+		LastCheck:            h.lastCheck,
+		LastError:            h.lastError,
+		ConsecutiveSuccesses: h.successCount,
+		ConsecutiveFailures:  h.failureCount,
+		LastTransition:       h.lastTransition,
+		History:              history,
+	}
+}
+
+// record appends the result to the bounded history ring buffer.
+// The caller must hold the lock.
+func (h *ProxyHealth) record(r Result) {
+	if h.historySize <= 0 {
+		return
+	}
+
+	if len(h.history) < h.historySize {
+		h.history = append(h.history, r)
+		return
+	}
+
+	h.history[h.historyNext] = r
+	h.historyNext = (h.historyNext + 1) % h.historySize
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httputil"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+)
+
+// Opts configures a Proxy.
+type Opts func(*Proxy)
+
+// WithHealth sets the health check of the proxy.
+// By default a health check with the default options is created for the proxy address.
+// The health events report the proxy name unless health.WithName was used.
+func WithHealth(h *health.ProxyHealth) Opts {
+	return func(p *Proxy) {
+		p.health = h
+	}
+}
+
+// WithLocality sets the region and zone of the proxy origin.
+func WithLocality(region, zone string) Opts {
+	return func(p *Proxy) {
+		p.locality = Locality{Region: region, Zone: zone}
+	}
+}
+
+// WithLabels adds key/value labels to the proxy, such as version or canary,
+// to filter and group proxies. The labels cannot be changed after creation.
+func WithLabels(labels map[string]string) Opts {
+	return func(p *Proxy) {
+		if p.labels == nil {
+			p.labels = make(map[string]string, len(labels))
+		}
+		for k, v := range labels {
+			p.labels[k] = v
+		}
+	}
+}
+
+// WithMirror mirrors the requests served by the proxy, for example
+// to send shadow traffic to a new version of the backend.
+func WithMirror(m Mirror) Opts {
+	return func(p *Proxy) {
+		p.mirror = m
+	}
+}
+
+// WithTransport sets the round tripper used to reach the proxy origin.
+// It takes precedence over the other transport options.
+// Defaults to http.DefaultTransport.
+func WithTransport(rt http.RoundTripper) Opts {
+	return func(p *Proxy) {
+		p.transport.roundTripper = rt
+	}
+}
+
+// WithDialTimeout sets the maximum time to establish a connection to the proxy origin.
+func WithDialTimeout(d time.Duration) Opts {
+	return func(p *Proxy) {
+		p.transport.dialTimeout = d
+		p.transport.configured = true
+	}
+}
+
+// WithTLSHandshakeTimeout sets the maximum time to complete the TLS handshake with the proxy origin.
+func WithTLSHandshakeTimeout(d time.Duration) Opts {
+	return func(p *Proxy) {
+		p.transport.tlsHandshakeTimeout = d
+		p.transport.configured = true
+	}
+}
+
+// WithResponseHeaderTimeout sets the maximum time to wait for the response
+// headers of the proxy origin after the request is written.
+// The proxy responds with 504 Gateway Timeout when it expires.
+func WithResponseHeaderTimeout(d time.Duration) Opts {
+	return func(p *Proxy) {
+		p.transport.responseHeaderTimeout = d
+		p.transport.configured = true
+	}
+}
+
+// WithMaxIdleConnsPerHost sets the maximum number of idle connections kept to the proxy origin.
+func WithMaxIdleConnsPerHost(n int) Opts {
+	return func(p *Proxy) {
+		p.transport.maxIdleConnsPerHost = n
+		p.transport.configured = true
+	}
+}
+
+// WithTLSClientConfig sets the TLS configuration used to reach the proxy origin.
+func WithTLSClientConfig(config *tls.Config) Opts {
+	return func(p *Proxy) {
+		p.transport.tlsClientConfig = config
+		p.transport.configured = true
+	}
+}
+
+// WithBufferPool sets the pool of byte slices used to copy the response bodies.
+func WithBufferPool(pool httputil.BufferPool) Opts {
+	return func(p *Proxy) {
+		p.proxy.BufferPool = pool
+	}
+}
+
+// WithFlushInterval sets the interval at which the response body is flushed
+// to the client, see httputil.ReverseProxy.FlushInterval.
+// A negative value flushes immediately after each write.
+func WithFlushInterval(d time.Duration) Opts {
+	return func(p *Proxy) {
+		p.proxy.FlushInterval = d
+	}
+}
+
+// WithErrorHandler sets the function writing the response when a request
+// cannot be forwarded to the proxy origin. The callbacks registered with
+// Proxy.OnError are still invoked. By default the proxy responds with
+// 504 Gateway Timeout on timeouts and 502 Bad Gateway otherwise.
+func WithErrorHandler(fn func(w http.ResponseWriter, r *http.Request, err error)) Opts {
+	return func(p *Proxy) {
+		p.errorHandler = fn
+	}
+}
+
+// WithXForwardedHeaders sets the X-Forwarded-Proto and X-Forwarded-Host headers
+// of the forwarded requests to the scheme and host requested by the client.
+// The X-Forwarded-For header is always set.
+func WithXForwardedHeaders() Opts {
+	return func(p *Proxy) {
+		p.headers.xForwarded = true
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithForwardedHeader appends the client address, the requested host and scheme
+// to the Forwarded header of the forwarded requests, as defined by RFC 7239.
+func WithForwardedHeader() Opts {
+	return func(p *Proxy) {
+		p.headers.forwarded = true
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithHost sets the Host header of the forwarded requests.
+// By default the Host header requested by the client is preserved.
+func WithHost(host string) Opts {
+	return func(p *Proxy) {
+		p.headers.host = host
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithOriginHost sets the Host header of the forwarded requests to the host of the proxy origin.
+// By default the Host header requested by the client is preserved.
+func WithOriginHost() Opts {
+	return func(p *Proxy) {
+		p.headers.originHost = true
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithRequestHeader sets a header of the forwarded requests, replacing any existing value.
+func WithRequestHeader(key, value string) Opts {
+	return func(p *Proxy) {
+		if p.headers.setRequest == nil {
+			p.headers.setRequest = make(http.Header)
+		}
+		p.headers.setRequest.Set(key, value)
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithRemoveRequestHeader removes headers from the forwarded requests.
+func WithRemoveRequestHeader(keys ...string) Opts {
+	return func(p *Proxy) {
+		p.headers.removeRequest = append(p.headers.removeRequest, keys...)
+		p.headers.requestRewrite = true
+	}
+}
+
+// WithResponseHeader sets a header of the responses, replacing any existing value.
+func WithResponseHeader(key, value string) Opts {
+	return func(p *Proxy) {
+		if p.headers.setResponse == nil {
+			p.headers.setResponse = make(http.Header)
+		}
+		p.headers.setResponse.Set(key, value)
+		p.headers.responseRewrite = true
+	}
+}
+
+// WithRemoveResponseHeader removes headers from the responses.
+func WithRemoveResponseHeader(keys ...string) Opts {
+	return func(p *Proxy) {
+		p.headers.removeResponse = append(p.headers.removeResponse, keys...)
+		p.headers.responseRewrite = true
+	}
+}
+
+// WithUpstreamHeader sets a header of the responses to the name of the proxy,
+// to find out which server handled a request. The header defaults to
+// X-Upstream when key is empty.
+func WithUpstreamHeader(key string) Opts {
+	return func(p *Proxy) {
+		if key == "" {
+			key = defaultUpstreamHeader
+		}
+		p.headers.upstreamHeader = key
+		p.headers.responseRewrite = true
+	}
+}
+
+// WithUpgradeIdleTimeout closes the upgraded connections, such as WebSockets,
+// when no data is sent either way for the duration. By default they are kept open.
+func WithUpgradeIdleTimeout(d time.Duration) Opts {
+	return func(p *Proxy) {
+		p.upgradeIdleTimeout = d
+	}
+}
@@ -1,10 +1,13 @@
 package proxy
 
 import (
+	"context"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
 )
@@ -16,33 +19,84 @@ import (
 
 var value int32 = -1
 
+// drainInterval is the interval at which Drain checks the loading of the proxy.
+const drainInterval = 10 * time.Millisecond
+
 // NewProxy creates a new instance of Proxy with the specified address.
-func NewProxy(name string, addr *url.URL) *Proxy {
-	return &Proxy{
-		name:   name,
-		proxy:  httputil.NewSingleHostReverseProxy(addr),
-		health: health.New(addr),
+func NewProxy(name string, addr *url.URL, opts ...Opts) *Proxy {
+	p := &Proxy{
+		name:  name,
+		addr:  addr,
+		proxy: httputil.NewSingleHostReverseProxy(addr),
+	}
+
+	for _, opt := range opts {
+		opt(p)
 	}
+
+	if p.health == nil {
+		h, err := health.New(addr, health.WithName(name))
+		if err != nil {
+			// the default health check options are always valid
+			panic(err)
+		}
+		p.health = h
+	}
+	// A health check set by WithHealth reports the proxy name unless it has its own.
+	p.health.SetDefaultName(name)
+
+	p.proxy.Transport = p.transport.build()
+	p.proxy.ErrorHandler = p.handleError
+	p.rewriteHeaders(addr)
+
+	return p
 }
 
 // Proxy represents a reverse proxy for load balancing algorithms.
 type Proxy struct {
-	name    string
-	proxy   *httputil.ReverseProxy
-	loading uint32
-	health  *health.ProxyHealth
+	name     string
+	addr     *url.URL
+	proxy    *httputil.ReverseProxy
+	loading  uint32
+	health   *health.ProxyHealth
+	locality Locality
+	labels   map[string]string
+	mirror   Mirror
+
+	transport    transportConfig
+	headers      headerConfig
+	errorHandler func(http.ResponseWriter, *http.Request, error)
+
+	upgrades           upgradeTracker
+	upgradeIdleTimeout time.Duration
+
+	errMu          sync.Mutex
+	errorListeners []errorListener
+	nextListenerID uint64
+}
+
+// Locality is the location of a proxy origin.
+type Locality struct {
+	Region string
+	Zone   string
 }
 
 // ServeHTTP handles the incoming HTTP request and forwards it to the underlying proxy server.
-// It increments the load counter by 1 before forwarding the request and decrements it by the given value after the request is processed.
+// It acquires the proxy before forwarding the request and releases it after the request is processed.
 // This method is part of the Proxy struct and implements the http.Handler interface.
 //
 // Parameters:
 // - w: The http.ResponseWriter used to write the response back to the client.
 // - r: The http.Request representing the incoming request.
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	atomic.AddUint32(&p.loading, 1)
-	defer atomic.AddUint32(&p.loading, uint32(value))
+	p.Acquire()
+	defer p.Release()
+	if p.mirror != nil {
+		r = p.mirror.Mirror(r)
+	}
+	if IsUpgrade(r) {
+		w = &upgradeWriter{ResponseWriter: w, proxy: p}
+	}
 	p.proxy.ServeHTTP(w, r)
 }
 
@@ -51,12 +105,125 @@ func (p *Proxy) GetLoading() uint32 {
 	return atomic.LoadUint32(&p.loading)
 }
 
+// Acquire increments the loading of the proxy for a request or a connection
+// forwarded outside of ServeHTTP, such as by a layer 4 load balancer.
+// Each call must be followed by a call to Release.
+func (p *Proxy) Acquire() {
+	atomic.AddUint32(&p.loading, 1)
+}
+
+// Release decrements the loading of the proxy once the request or the
+// connection counted by Acquire is done.
+func (p *Proxy) Release() {
+	atomic.AddUint32(&p.loading, uint32(value))
+}
+
+// Drain blocks until the proxy has no in-flight requests or the context is done,
+// in which case it returns the context error.
+// The upgraded connections are in-flight requests until they are closed, see CloseUpgraded.
+func (p *Proxy) Drain(ctx context.Context) error {
+	if p.GetLoading() == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if p.GetLoading() == 0 {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 // GetName returns the name of the proxy.
 func (p *Proxy) GetName() string {
 	return p.name
 }
 
+// Addr returns a copy of the address of the proxy origin.
+func (p *Proxy) Addr() *url.URL {
+	addr := *p.addr
+	return &addr
+}
+
+// Locality returns the location of the proxy origin.
+func (p *Proxy) Locality() Locality {
+	return p.locality
+}
+
+// Label returns the value of the label and whether the proxy has it.
+func (p *Proxy) Label(key string) (string, bool) {
+	v, ok := p.labels[key]
+	return v, ok
+}
+
+// Labels returns a copy of the labels of the proxy.
+func (p *Proxy) Labels() map[string]string {
+	labels := make(map[string]string, len(p.labels))
+	for k, v := range p.labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// MatchLabels reports whether the proxy has all the labels of the selector
+// with the same values. An empty selector matches every proxy.
+func (p *Proxy) MatchLabels(selector map[string]string) bool {
+	for k, v := range selector {
+		if got, ok := p.labels[k]; !ok || got != v {
+			return false
+		}
+	}
+	return true
+}
+
 // IsAvailable returns whether the proxy origin was successfully connected at the last check time.
 func (p *Proxy) IsAvailable() bool {
 	return p.health.IsAvailable()
 }
+
+// State returns the health state of the proxy origin, which is
+// health.StateUnknown until enough checks have completed.
+func (p *Proxy) State() health.State {
+	return p.health.State()
+}
+
+// Start starts the health check of the proxy if it is stopped, for example
+// after Close. Load balancers call it for the servers they add, so that a
+// server removed and added again is checked again.
+func (p *Proxy) Start() {
+	p.health.Start()
+}
+
+// Close stops the health check of the proxy and closes its upgraded connections.
+// Load balancers call it for the servers they remove: the load balancer holding
+// a proxy owns its lifecycle, so a proxy must be removed from a load balancer
+// before it is added to another one.
+func (p *Proxy) Close() error {
+	p.health.Stop()
+	p.CloseUpgraded()
+	return nil
+}
+
+// OnStatusChange registers fn to be called every time the availability of the proxy changes.
+// The returned function unregisters the callback.
+func (p *Proxy) OnStatusChange(fn func(health.Event)) func() {
+	return p.health.OnStatusChange(fn)
+}
+
+// Subscribe returns a channel that receives a health.Event every time the availability
+// of the proxy changes. The returned function unregisters the subscription.
+func (p *Proxy) Subscribe(size int) (<-chan health.Event, func()) {
+	return p.health.Subscribe(size)
+}
+
+// Status returns a snapshot of the health of the proxy origin.
+func (p *Proxy) Status() health.Status {
+	return p.health.Status()
+}
@@ -1,10 +1,16 @@
 package proxy
 
 import (
+	"bufio"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
 )
 
 func TestProxy_ServeHTTP(t *testing.T) {
@@ -32,3 +38,318 @@ func TestProxy_ServeHTTP(t *testing.T) {
 		t.Errorf("expected status code %d, got %d", http.StatusOK, rr.Code)
 	}
 }
+
+func TestProxy_Labels(t *testing.T) {
+	addr := &url.URL{Host: "192.168.1.10"}
+	labels := map[string]string{"version": "v2", "zone": "a"}
+	p1 := NewProxy("s1", addr, WithLabels(labels), WithLabels(map[string]string{"canary": "true"}))
+	p2 := NewProxy("s2", addr, WithLabels(map[string]string{"version": "v1"}))
+	defer p1.Close()
+	defer p2.Close()
+
+	// Changing the original map does not change the labels.
+	labels["version"] = "v3"
+
+	if v, ok := p1.Label("version"); !ok || v != "v2" {
+		t.Errorf("Label(version) = (%s, %v), want (v2, true)", v, ok)
+	}
+	if _, ok := p1.Label("missing"); ok {
+		t.Errorf("Expected missing label")
+	}
+	if got := len(p1.Labels()); got != 3 {
+		t.Errorf("Expected 3 labels, but got %d", got)
+	}
+
+	matched := Filter([]*Proxy{p1, p2}, map[string]string{"version": "v2", "canary": "true"})
+	if len(matched) != 1 || matched[0] != p1 {
+		t.Errorf("Expected only s1 to match, but got %d proxies", len(matched))
+	}
+	if got := len(Filter([]*Proxy{p1, p2}, nil)); got != 2 {
+		t.Errorf("Expected an empty selector to match all proxies, but got %d", got)
+	}
+}
+
+func TestProxy_Errors(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	slowURL, _ := url.Parse(slow.URL)
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL, _ := url.Parse(closed.URL)
+	closed.Close()
+
+	tests := []struct {
+		name string
+		addr *url.URL
+		opts []Opts
+		code int
+	}{
+		{name: "unreachable", addr: closedURL, code: http.StatusBadGateway},
+		{
+			name: "response header timeout",
+			addr: slowURL,
+			opts: []Opts{WithResponseHeaderTimeout(20 * time.Millisecond)},
+			code: http.StatusGatewayTimeout,
+		},
+		{
+			name: "error handler",
+			addr: closedURL,
+			opts: []Opts{WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			})},
+			code: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := health.New(tt.addr, health.WithInitialDelay(time.Hour))
+			p := NewProxy("s1", tt.addr, append(tt.opts, WithHealth(h))...)
+			defer p.Close()
+
+			var reported error
+			unregister := p.OnError(func(r *http.Request, err error) {
+				reported = err
+			})
+			defer unregister()
+
+			rr := httptest.NewRecorder()
+			p.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+
+			if rr.Code != tt.code {
+				t.Errorf("ServeHTTP() status = %d, want %d", rr.Code, tt.code)
+			}
+			if reported == nil {
+				t.Error("OnError() callback was not invoked")
+			}
+		})
+	}
+}
+
+func TestProxy_Headers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Echo-Host", r.Host)
+		for _, key := range []string{"X-Forwarded-Proto", "X-Forwarded-Host", "Forwarded", "X-Api-Key", "Cookie"} {
+			w.Header().Set("Echo-"+key, r.Header.Get(key))
+		}
+		w.Header().Set("X-Internal", "secret")
+	}))
+	defer ts.Close()
+	addr, _ := url.Parse(ts.URL)
+
+	tests := []struct {
+		name string
+		opts []Opts
+		want map[string]string
+	}{
+		{
+			name: "defaults",
+			want: map[string]string{"Echo-Host": "example.com", "Echo-X-Forwarded-Proto": "", "X-Internal": "secret"},
+		},
+		{
+			name: "x-forwarded",
+			opts: []Opts{WithXForwardedHeaders()},
+			want: map[string]string{"Echo-X-Forwarded-Proto": "http", "Echo-X-Forwarded-Host": "example.com"},
+		},
+		{
+			name: "forwarded",
+			opts: []Opts{WithForwardedHeader()},
+			want: map[string]string{"Echo-Forwarded": "for=192.0.2.1;host=example.com;proto=http"},
+		},
+		{
+			name: "origin host",
+			opts: []Opts{WithOriginHost()},
+			want: map[string]string{"Echo-Host": addr.Host},
+		},
+		{
+			name: "host",
+			opts: []Opts{WithHost("backend.internal")},
+			want: map[string]string{"Echo-Host": "backend.internal"},
+		},
+		{
+			name: "request headers",
+			opts: []Opts{WithRequestHeader("X-Api-Key", "key"), WithRemoveRequestHeader("Cookie")},
+			want: map[string]string{"Echo-X-Api-Key": "key", "Echo-Cookie": ""},
+		},
+		{
+			name: "response headers",
+			opts: []Opts{
+				WithResponseHeader("X-Frame-Options", "DENY"),
+				WithRemoveResponseHeader("X-Internal"),
+				WithUpstreamHeader(""),
+			},
+			want: map[string]string{"X-Frame-Options": "DENY", "X-Internal": "", "X-Upstream": "s1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := health.New(addr, health.WithInitialDelay(time.Hour))
+			p := NewProxy("s1", addr, append(tt.opts, WithHealth(h))...)
+			defer p.Close()
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header.Set("Cookie", "session=1")
+			rr := httptest.NewRecorder()
+			p.ServeHTTP(rr, req)
+
+			for key, want := range tt.want {
+				if got := rr.Header().Get(key); got != want {
+					t.Errorf("Header(%s) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// newEchoUpgradeServer returns a server accepting "echo" upgrades and echoing the received data.
+func newEchoUpgradeServer(t *testing.T) *url.URL {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "echo" {
+			return
+		}
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		_ = brw.Flush()
+		_, _ = io.Copy(conn, brw)
+	}))
+	t.Cleanup(ts.Close)
+	addr, _ := url.Parse(ts.URL)
+	return addr
+}
+
+// dialUpgrade opens an upgraded connection through the front server.
+func dialUpgrade(t *testing.T, front *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", front.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_, _ = io.WriteString(conn, "GET / HTTP/1.1\r\nHost: example.com\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Failed to read the upgrade response: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Upgrade status = %d, want %d", resp.StatusCode, http.StatusSwitchingProtocols)
+	}
+	return conn, br
+}
+
+// waitUpgraded waits until the proxy has the given number of upgraded connections.
+func waitUpgraded(t *testing.T, p *Proxy, want uint32) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for p.GetUpgraded() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetUpgraded() = %d, want %d", p.GetUpgraded(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestProxy_Upgrade(t *testing.T) {
+	addr := newEchoUpgradeServer(t)
+
+	t.Run("counts and closes upgraded connections", func(t *testing.T) {
+		h, _ := health.New(addr, health.WithInitialDelay(time.Hour))
+		p := NewProxy("s1", addr, WithHealth(h))
+		front := httptest.NewServer(p)
+		defer front.Close()
+
+		conn, br := dialUpgrade(t, front)
+		_, _ = io.WriteString(conn, "ping")
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(br, buf); err != nil || string(buf) != "ping" {
+			t.Fatalf("Echo = %q, %v, want ping", buf, err)
+		}
+
+		waitUpgraded(t, p, 1)
+		if got := p.GetLoading(); got != 1 {
+			t.Errorf("GetLoading() = %d, want 1", got)
+		}
+
+		_ = p.Close()
+		waitUpgraded(t, p, 0)
+		if _, err := br.ReadByte(); err == nil {
+			t.Error("Expected the upgraded connection to be closed")
+		}
+	})
+
+	t.Run("idle timeout", func(t *testing.T) {
+		h, _ := health.New(addr, health.WithInitialDelay(time.Hour))
+		p := NewProxy("s1", addr, WithHealth(h), WithUpgradeIdleTimeout(50*time.Millisecond))
+		defer p.Close()
+		front := httptest.NewServer(p)
+		defer front.Close()
+
+		_, br := dialUpgrade(t, front)
+		waitUpgraded(t, p, 1)
+
+		if _, err := br.ReadByte(); err == nil {
+			t.Error("Expected the idle connection to be closed")
+		}
+		waitUpgraded(t, p, 0)
+	})
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   bool
+	}{
+		{name: "plain request", header: http.Header{}, want: false},
+		{name: "websocket", header: http.Header{"Connection": {"Upgrade"}, "Upgrade": {"websocket"}}, want: true},
+		{name: "connection options", header: http.Header{"Connection": {"keep-alive, upgrade"}, "Upgrade": {"websocket"}}, want: true},
+		{name: "no connection option", header: http.Header{"Upgrade": {"websocket"}}, want: false},
+		{name: "no upgrade header", header: http.Header{"Connection": {"Upgrade"}}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header = tt.header
+			if got := IsUpgrade(req); got != tt.want {
+				t.Errorf("IsUpgrade() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxy_HealthName(t *testing.T) {
+	addr := &url.URL{Host: "192.168.1.10"}
+	tests := []struct {
+		name string
+		opts []health.Opts
+		want string
+	}{
+		{name: "unnamed", want: "s1"},
+		{name: "named", opts: []health.Opts{health.WithName("primary")}, want: "primary"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := health.New(addr, append(tt.opts, health.WithInitialDelay(time.Hour))...)
+			p := NewProxy("s1", addr, WithHealth(h))
+			defer p.Close()
+
+			if got := h.Name(); got != tt.want {
+				t.Errorf("Name() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// transportConfig holds the settings of the transport used to reach the proxy origin.
+type transportConfig struct {
+	roundTripper          http.RoundTripper
+	dialTimeout           time.Duration
+	tlsHandshakeTimeout   time.Duration
+	responseHeaderTimeout time.Duration
+	maxIdleConnsPerHost   int
+	tlsClientConfig       *tls.Config
+	configured            bool
+}
+
+// build returns the round tripper for the configuration, or nil to use
+// http.DefaultTransport when nothing was configured.
+func (c transportConfig) build() http.RoundTripper {
+	if c.roundTripper != nil {
+		return c.roundTripper
+	}
+	if !c.configured {
+		return nil
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.dialTimeout > 0 {
+		t.DialContext = (&net.Dialer{
+			Timeout:   c.dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if c.tlsHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = c.tlsHandshakeTimeout
+	}
+	if c.responseHeaderTimeout > 0 {
+		t.ResponseHeaderTimeout = c.responseHeaderTimeout
+	}
+	if c.maxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = c.maxIdleConnsPerHost
+	}
+	if c.tlsClientConfig != nil {
+		t.TLSClientConfig = c.tlsClientConfig
+	}
+	return t
+}
+
+// errorListener is a registered receiver of the proxy errors.
+type errorListener struct {
+	id uint64
+	fn func(*http.Request, error)
+}
+
+// OnError registers fn to be called every time a request cannot be forwarded
+// to the proxy origin, so that a load balancer can react to the failures.
+// The callbacks are invoked synchronously while serving the request,
+// so they must not block. The returned function unregisters the callback.
+func (p *Proxy) OnError(fn func(r *http.Request, err error)) func() {
+	p.errMu.Lock()
+	p.nextListenerID++
+	id := p.nextListenerID
+	p.errorListeners = append(p.errorListeners, errorListener{id: id, fn: fn})
+	p.errMu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.errMu.Lock()
+			defer p.errMu.Unlock()
+			for i, l := range p.errorListeners {
+				if l.id == id {
+					p.errorListeners = append(p.errorListeners[:i:i], p.errorListeners[i+1:]...)
+					return
+				}
+			}
+		})
+	}
+}
+
+// handleError is the error handler of the reverse proxy. It notifies the
+// error listeners, unless the request was cancelled by the client, and
+// writes the response with the handler set by WithErrorHandler, or responds
+// with 504 Gateway Timeout on timeouts and 502 Bad Gateway otherwise.
+func (p *Proxy) handleError(w http.ResponseWriter, r *http.Request, err error) {
+	if !errors.Is(r.Context().Err(), context.Canceled) {
+		p.errMu.Lock()
+		listeners := p.errorListeners
+		p.errMu.Unlock()
+
+		for _, l := range listeners {
+			l.fn(r, err)
+		}
+	}
+
+	if p.headers.responseRewrite {
+		p.headers.rewriteResponse(w.Header(), p.name)
+	}
+
+	if p.errorHandler != nil {
+		p.errorHandler(w, r, err)
+		return
+	}
+
+	code := http.StatusBadGateway
+	if isTimeout(err) {
+		code = http.StatusGatewayTimeout
+	}
+	http.Error(w, http.StatusText(code), code)
+}
+
+// isTimeout reports whether the error is caused by a timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
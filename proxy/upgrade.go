@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// upgradeTracker holds the connections upgraded through the proxy, such as WebSockets.
+type upgradeTracker struct {
+	mu    sync.Mutex
+	conns map[*upgradedConn]struct{}
+	count uint32
+}
+
+// IsUpgrade reports whether the request asks for a protocol upgrade, such as
+// WebSocket: it has an Upgrade header and an upgrade Connection option.
+func IsUpgrade(r *http.Request) bool {
+	if r.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, value := range r.Header["Connection"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetUpgraded returns the number of open upgraded connections, such as WebSockets.
+// They are also counted by GetLoading for their whole lifetime.
+func (p *Proxy) GetUpgraded() uint32 {
+	return atomic.LoadUint32(&p.upgrades.count)
+}
+
+// CloseUpgraded closes the open upgraded connections and returns how many were closed.
+// It is called by Close when the proxy is removed from a load balancer.
+func (p *Proxy) CloseUpgraded() int {
+	p.upgrades.mu.Lock()
+	conns := make([]*upgradedConn, 0, len(p.upgrades.conns))
+	for c := range p.upgrades.conns {
+		conns = append(conns, c)
+	}
+	p.upgrades.mu.Unlock()
+
+	for _, c := range conns {
+		_ = c.Close()
+	}
+	return len(conns)
+}
+
+// track registers the upgraded connection.
+func (p *Proxy) track(c *upgradedConn) {
+	p.upgrades.mu.Lock()
+	defer p.upgrades.mu.Unlock()
+
+	if p.upgrades.conns == nil {
+		p.upgrades.conns = make(map[*upgradedConn]struct{})
+	}
+	p.upgrades.conns[c] = struct{}{}
+	atomic.AddUint32(&p.upgrades.count, 1)
+}
+
+// untrack unregisters the upgraded connection.
+func (p *Proxy) untrack(c *upgradedConn) {
+	p.upgrades.mu.Lock()
+	defer p.upgrades.mu.Unlock()
+
+	delete(p.upgrades.conns, c)
+	atomic.AddUint32(&p.upgrades.count, uint32(value))
+}
+
+// upgradeWriter wraps the response writer of an upgrade request
+// to track the connection hijacked by the reverse proxy.
+type upgradeWriter struct {
+	http.ResponseWriter
+	proxy *Proxy
+}
+
+// Hijack takes over the client connection once the origin accepted the upgrade.
+func (w *upgradeWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("proxy: response writer does not support hijacking")
+	}
+
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &upgradedConn{Conn: conn, proxy: w.proxy, idleTimeout: w.proxy.upgradeIdleTimeout}
+	w.proxy.track(c)
+	return c, brw, nil
+}
+
+// Unwrap returns the wrapped response writer, for http.ResponseController.
+func (w *upgradeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// upgradedConn is a hijacked client connection. It is closed when no data
+// is read or written for the idle timeout.
+type upgradedConn struct {
+	net.Conn
+	proxy       *Proxy
+	idleTimeout time.Duration
+	closing     sync.Once
+}
+
+func (c *upgradedConn) Read(b []byte) (int, error) {
+	c.extend()
+	return c.Conn.Read(b)
+}
+
+func (c *upgradedConn) Write(b []byte) (int, error) {
+	c.extend()
+	return c.Conn.Write(b)
+}
+
+// Close closes the connection and stops tracking it. It is safe to call Close multiple times.
+func (c *upgradedConn) Close() error {
+	err := net.ErrClosed
+	c.closing.Do(func() {
+		err = c.Conn.Close()
+		c.proxy.untrack(c)
+	})
+	return err
+}
+
+// extend pushes back the idle deadline of the connection.
+// The deadline covers both directions, so that a connection is idle
+// only when no data flows either way.
+func (c *upgradedConn) extend() {
+	if c.idleTimeout > 0 {
+		_ = c.Conn.SetDeadline(time.Now().Add(c.idleTimeout))
+	}
+}
@@ -0,0 +1,74 @@
+package proxyproto
+
+import (
+	"bufio"
+	"net"
+	"time"
+)
+
+// Conn is a connection whose PROXY protocol header has been read.
+// Its addresses are the ones carried by the header, when known.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	header *Header
+}
+
+// Accept reads the PROXY protocol header of the connection, waiting at most
+// timeout for it when timeout is positive. The connection must be closed by
+// the caller if an error is returned. A header is required; connections that
+// do not start with a header fail with ErrNoHeader.
+func Accept(conn net.Conn, timeout time.Duration) (*Conn, error) {
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+	}
+
+	r := bufio.NewReader(conn)
+	h, err := Read(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Conn{Conn: conn, reader: r, header: h}, nil
+}
+
+// Read reads the data following the header.
+func (c *Conn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+// Header returns the PROXY protocol header of the connection.
+func (c *Conn) Header() *Header {
+	return c.header
+}
+
+// RemoteAddr returns the client address carried by the header, or the
+// address of the peer for LOCAL or UNKNOWN headers.
+func (c *Conn) RemoteAddr() net.Addr {
+	if c.header.Command == CommandProxy && c.header.Source != nil {
+		return c.header.Source
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address carried by the header, or the
+// local address for LOCAL or UNKNOWN headers.
+func (c *Conn) LocalAddr() net.Addr {
+	if c.header.Command == CommandProxy && c.header.Destination != nil {
+		return c.header.Destination
+	}
+	return c.Conn.LocalAddr()
+}
+
+// NetConn returns the underlying connection.
+func (c *Conn) NetConn() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,291 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoHeader is returned when the data does not start with a PROXY protocol header.
+	ErrNoHeader = errors.New("proxyproto: no PROXY protocol header")
+	// ErrInvalidHeader is returned when a PROXY protocol header is malformed.
+	ErrInvalidHeader = errors.New("proxyproto: invalid PROXY protocol header")
+	// ErrInvalidVersion is returned when a header is written with an unknown version.
+	ErrInvalidVersion = errors.New("proxyproto: version must be 1 or 2")
+)
+
+// Version is the version of the PROXY protocol.
+type Version int
+
+const (
+	// V1 is the human-readable version of the protocol.
+	V1 Version = 1
+	// V2 is the binary version of the protocol.
+	V2 Version = 2
+)
+
+// Command is the command of a version 2 header.
+type Command byte
+
+const (
+	// CommandLocal marks a connection established by the proxy itself, such as a
+	// health check. The addresses of the connection must be used.
+	CommandLocal Command = 0x0
+	// CommandProxy marks a connection relayed on behalf of a client.
+	CommandProxy Command = 0x1
+)
+
+const (
+	// Maximum length of a version 1 header, including the CRLF
+	v1MaxLength = 107
+	// Length of the fixed part of a version 2 header
+	v2HeaderLength = 16
+)
+
+// v1Prefix starts a version 1 header.
+var v1Prefix = []byte("PROXY ")
+
+// v2Signature starts a version 2 header.
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Header is a PROXY protocol header, carrying the addresses of a connection
+// relayed by a proxy, as specified by HAProxy.
+type Header struct {
+	Version Version
+	Command Command
+	// Source is the address of the client, a *net.TCPAddr or *net.UDPAddr.
+	// It is nil when the addresses are unknown.
+	Source net.Addr
+	// Destination is the address the client connected to.
+	Destination net.Addr
+}
+
+// Read reads a version 1 or 2 header from the reader.
+// It returns ErrNoHeader, without consuming any data, if the
+// data does not start with a header.
+func Read(r *bufio.Reader) (*Header, error) {
+	b, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, v1Prefix) {
+		return readV1(r)
+	}
+
+	b, err = r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(b, v2Signature) {
+		return readV2(r)
+	}
+
+	return nil, ErrNoHeader
+}
+
+// readV1 reads a version 1 header, such as "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n".
+func readV1(r *bufio.Reader) (*Header, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Header{Version: V1, Command: CommandProxy}
+	fields := strings.Split(line, " ")
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return h, nil
+	}
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	src, dst := net.ParseIP(fields[2]), net.ParseIP(fields[3])
+	if src == nil || dst == nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+	switch fields[1] {
+	case "TCP4":
+		if src.To4() == nil || dst.To4() == nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+		}
+	case "TCP6":
+		if src.To4() != nil || dst.To4() != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	srcPort, err1 := strconv.ParseUint(fields[4], 10, 16)
+	dstPort, err2 := strconv.ParseUint(fields[5], 10, 16)
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHeader, line)
+	}
+
+	h.Source = &net.TCPAddr{IP: src, Port: int(srcPort)}
+	h.Destination = &net.TCPAddr{IP: dst, Port: int(dstPort)}
+	return h, nil
+}
+
+// readLine reads a CRLF terminated line of at most v1MaxLength bytes.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, c)
+		if c == '\n' {
+			if !bytes.HasSuffix(line, []byte("\r\n")) {
+				break
+			}
+			return string(line[:len(line)-2]), nil
+		}
+	}
+	return "", fmt.Errorf("%w: line too long or not CRLF terminated", ErrInvalidHeader)
+}
+
+// readV2 reads a version 2 header. The TLVs are skipped.
+func readV2(r *bufio.Reader) (*Header, error) {
+	var fixed [v2HeaderLength]byte
+	if _, err := io.ReadFull(r, fixed[:]); err != nil {
+		return nil, err
+	}
+
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("%w: version %d", ErrInvalidHeader, fixed[12]>>4)
+	}
+	h := &Header{Version: V2, Command: Command(fixed[12] & 0xF)}
+	if h.Command != CommandLocal && h.Command != CommandProxy {
+		return nil, fmt.Errorf("%w: command %d", ErrInvalidHeader, h.Command)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+
+	if h.Command == CommandLocal {
+		return h, nil
+	}
+
+	var size int
+	switch fixed[13] >> 4 {
+	case 0x1:
+		size = net.IPv4len
+	case 0x2:
+		size = net.IPv6len
+	default:
+		// Unspecified or UNIX addresses are unknown.
+		return h, nil
+	}
+
+	if len(payload) < 2*size+4 {
+		return nil, fmt.Errorf("%w: address block too short", ErrInvalidHeader)
+	}
+	src := net.IP(append([]byte(nil), payload[:size]...))
+	dst := net.IP(append([]byte(nil), payload[size:2*size]...))
+	srcPort := int(binary.BigEndian.Uint16(payload[2*size:]))
+	dstPort := int(binary.BigEndian.Uint16(payload[2*size+2:]))
+
+	switch fixed[13] & 0xF {
+	case 0x1:
+		h.Source = &net.TCPAddr{IP: src, Port: srcPort}
+		h.Destination = &net.TCPAddr{IP: dst, Port: dstPort}
+	case 0x2:
+		h.Source = &net.UDPAddr{IP: src, Port: srcPort}
+		h.Destination = &net.UDPAddr{IP: dst, Port: dstPort}
+	}
+	return h, nil
+}
+
+// Format returns the header encoded in its version. Version 1 headers can only
+// carry TCP addresses; other addresses are sent as UNKNOWN.
+func (h *Header) Format() ([]byte, error) {
+	switch h.Version {
+	case V1:
+		return h.formatV1(), nil
+	case V2:
+		return h.formatV2(), nil
+	default:
+		return nil, ErrInvalidVersion
+	}
+}
+
+// WriteTo writes the encoded header to w.
+func (h *Header) WriteTo(w io.Writer) (int64, error) {
+	b, err := h.Format()
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+func (h *Header) formatV1() []byte {
+	src, srcOK := h.Source.(*net.TCPAddr)
+	dst, dstOK := h.Destination.(*net.TCPAddr)
+	if h.Command == CommandLocal || !srcOK || !dstOK || src == nil || dst == nil {
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	proto := "TCP6"
+	srcIP, dstIP := src.IP.To4(), dst.IP.To4()
+	if srcIP != nil && dstIP != nil {
+		proto = "TCP4"
+	} else {
+		srcIP, dstIP = src.IP.To16(), dst.IP.To16()
+	}
+
+	return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n", proto, srcIP, dstIP, src.Port, dst.Port))
+}
+
+func (h *Header) formatV2() []byte {
+	b := append([]byte(nil), v2Signature...)
+	b = append(b, 0x20|byte(h.Command&0xF))
+
+	srcIP, srcPort, transport := splitAddr(h.Source)
+	dstIP, dstPort, dstTransport := splitAddr(h.Destination)
+	if h.Command == CommandLocal || transport == 0 || transport != dstTransport {
+		return append(b, 0x00, 0, 0)
+	}
+
+	family := byte(0x2)
+	if srcIP.To4() != nil && dstIP.To4() != nil {
+		family = 0x1
+		srcIP, dstIP = srcIP.To4(), dstIP.To4()
+	} else {
+		srcIP, dstIP = srcIP.To16(), dstIP.To16()
+	}
+
+	b = append(b, family<<4|transport)
+	b = binary.BigEndian.AppendUint16(b, uint16(2*len(srcIP)+4))
+	b = append(b, srcIP...)
+	b = append(b, dstIP...)
+	b = binary.BigEndian.AppendUint16(b, uint16(srcPort))
+	return binary.BigEndian.AppendUint16(b, uint16(dstPort))
+}
+
+// splitAddr returns the IP address, the port and the version 2 transport of the address,
+// 0x1 for TCP and 0x2 for UDP, or a zero transport if the address is not supported.
+func splitAddr(addr net.Addr) (net.IP, int, byte) {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		if a != nil && a.IP != nil {
+			return a.IP, a.Port, 0x1
+		}
+	case *net.UDPAddr:
+		if a != nil && a.IP != nil {
+			return a.IP, a.Port, 0x2
+		}
+	}
+	return nil, 0, 0
+}
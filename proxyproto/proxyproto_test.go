@@ -0,0 +1,158 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRead(t *testing.T) {
+	v2TCP4 := append([]byte(nil), v2Signature...)
+	v2TCP4 = append(v2TCP4, 0x21, 0x11, 0, 12+3, 192, 0, 2, 1, 192, 0, 2, 2, 0xdc, 0x04, 0x01, 0xbb)
+	// A TLV is skipped.
+	v2TCP4 = append(v2TCP4, 0x04, 0, 0)
+	v2Local := append(append([]byte(nil), v2Signature...), 0x20, 0x00, 0, 0)
+	v2BadVersion := append(append([]byte(nil), v2Signature...), 0x11, 0x00, 0, 0)
+
+	tests := []struct {
+		name    string
+		data    string
+		source  string
+		version Version
+		command Command
+		err     error
+	}{
+		{name: "v1 tcp4", data: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n", source: "192.0.2.1:56324", version: V1, command: CommandProxy},
+		{name: "v1 tcp6", data: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", source: "[2001:db8::1]:56324", version: V1, command: CommandProxy},
+		{name: "v1 unknown", data: "PROXY UNKNOWN ignored\r\n", version: V1, command: CommandProxy},
+		{name: "v1 family mismatch", data: "PROXY TCP4 2001:db8::1 192.0.2.2 56324 443\r\n", err: ErrInvalidHeader},
+		{name: "v1 invalid port", data: "PROXY TCP4 192.0.2.1 192.0.2.2 70000 443\r\n", err: ErrInvalidHeader},
+		{name: "v1 missing CR", data: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\n", err: ErrInvalidHeader},
+		{name: "v1 too long", data: "PROXY " + strings.Repeat("x", 120) + "\r\n", err: ErrInvalidHeader},
+		{name: "v2 tcp4", data: string(v2TCP4), source: "192.0.2.1:56324", version: V2, command: CommandProxy},
+		{name: "v2 local", data: string(v2Local), version: V2, command: CommandLocal},
+		{name: "v2 invalid version", data: string(v2BadVersion), err: ErrInvalidHeader},
+		{name: "no header", data: "GET / HTTP/1.1\r\n\r\n", err: ErrNoHeader},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := Read(bufio.NewReader(strings.NewReader(tt.data)))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Read() error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			if h.Version != tt.version || h.Command != tt.command {
+				t.Errorf("Read() = version %d command %d, want %d %d", h.Version, h.Command, tt.version, tt.command)
+			}
+			source := ""
+			if h.Source != nil {
+				source = h.Source.String()
+			}
+			if source != tt.source {
+				t.Errorf("Read() source = %q, want %q", source, tt.source)
+			}
+		})
+	}
+}
+
+func TestHeader_Format(t *testing.T) {
+	tcp4 := [2]net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 56324},
+		&net.TCPAddr{IP: net.ParseIP("192.0.2.2"), Port: 443},
+	}
+	tcp6 := [2]net.Addr{
+		&net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 56324},
+		&net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 443},
+	}
+	udp4 := [2]net.Addr{
+		&net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353},
+		&net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 53},
+	}
+
+	tests := []struct {
+		name    string
+		version Version
+		addrs   [2]net.Addr
+		v1      string
+		known   bool
+	}{
+		{name: "v1 tcp4", version: V1, addrs: tcp4, v1: "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n", known: true},
+		{name: "v1 tcp6", version: V1, addrs: tcp6, v1: "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n", known: true},
+		{name: "v1 udp", version: V1, addrs: udp4, v1: "PROXY UNKNOWN\r\n"},
+		{name: "v2 tcp4", version: V2, addrs: tcp4, known: true},
+		{name: "v2 tcp6", version: V2, addrs: tcp6, known: true},
+		{name: "v2 udp4", version: V2, addrs: udp4, known: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Header{Version: tt.version, Command: CommandProxy, Source: tt.addrs[0], Destination: tt.addrs[1]}
+			var buf bytes.Buffer
+			if _, err := h.WriteTo(&buf); err != nil {
+				t.Fatalf("WriteTo() error = %v", err)
+			}
+			if tt.v1 != "" && buf.String() != tt.v1 {
+				t.Errorf("WriteTo() = %q, want %q", buf.String(), tt.v1)
+			}
+
+			// The header reads back with the same addresses.
+			got, err := Read(bufio.NewReader(&buf))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if !tt.known {
+				if got.Source != nil {
+					t.Errorf("Read() source = %v, want unknown", got.Source)
+				}
+				return
+			}
+			if got.Source.String() != tt.addrs[0].String() || got.Destination.String() != tt.addrs[1].String() {
+				t.Errorf("Read() = %v -> %v, want %v -> %v", got.Source, got.Destination, tt.addrs[0], tt.addrs[1])
+			}
+			if got.Source.Network() != tt.addrs[0].Network() {
+				t.Errorf("Read() network = %s, want %s", got.Source.Network(), tt.addrs[0].Network())
+			}
+		})
+	}
+
+	if _, err := (&Header{Version: 3}).Format(); !errors.Is(err, ErrInvalidVersion) {
+		t.Errorf("Format() error = %v, want %v", err, ErrInvalidVersion)
+	}
+}
+
+func TestAccept(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, _ = io.WriteString(client, "PROXY TCP4 203.0.113.7 192.0.2.2 1234 443\r\nhello")
+	}()
+
+	conn, err := Accept(server, time.Second)
+	if err != nil {
+		t.Fatalf("Accept() error = %v", err)
+	}
+
+	if got := conn.RemoteAddr().String(); got != "203.0.113.7:1234" {
+		t.Errorf("RemoteAddr() = %s, want 203.0.113.7:1234", got)
+	}
+	if got := conn.LocalAddr().String(); got != "192.0.2.2:443" {
+		t.Errorf("LocalAddr() = %s, want 192.0.2.2:443", got)
+	}
+
+	// The data following the header is not lost.
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Read() = %q, %v, want hello", buf, err)
+	}
+}
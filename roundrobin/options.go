@@ -0,0 +1,25 @@
+package roundrobin
+
+import "github.com/appleboy/loadbalancer-algorithms/slowstart"
+
+// Opts configures a round-robin load balancer.
+type Opts func(*roundrobin)
+
+// WithHealthyOnly makes NextServer skip servers that are not available.
+// Servers whose health state is still unknown, for example right after a
+// deploy, are selected only if includeUnknown is true.
+func WithHealthyOnly(includeUnknown bool) Opts {
+	return func(r *roundrobin) {
+		r.healthyOnly = true
+		r.includeUnknown = includeUnknown
+	}
+}
+
+// WithSlowStart ramps up the share of requests sent to servers when they are
+// added, including the servers the load balancer is created with, and to servers
+// that become healthy again, over the slow start window.
+func WithSlowStart(s *slowstart.SlowStart) Opts {
+	return func(r *roundrobin) {
+		r.slowStart = s
+	}
+}
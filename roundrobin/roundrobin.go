@@ -1,31 +1,47 @@
 package roundrobin
 
 import (
+	"context"
 	"errors"
 	"sync"
 	"sync/atomic"
 
 	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/slowstart"
 )
 
 // ErrServersEmpty is returned when the server list is empty.
 var ErrServersEmpty = errors.New("server list is empty")
 
 // RoundRobin is an interface that defines the methods for a round-robin load balancer algorithm.
+//
+// The load balancer owns the lifecycle of its servers: it starts their health
+// checks when they are added and stops them when they are removed. A server
+// removed from a load balancer can be added again, to the same or to another
+// one, but it must not be held by two load balancers at the same time.
 type RoundRobin interface {
 	// NextServer returns the next server in the rotation.
 	NextServer() *proxy.Proxy
 
-	// AddServers adds one or more servers to the load balancer.
+	// AddServers adds one or more servers to the load balancer
+	// and starts their health checks.
 	AddServers(...*proxy.Proxy) error
 
-	// RemoveServers removes one or more servers from the load balancer.
+	// RemoveServers removes one or more servers from the load balancer
+	// and stops their health checks.
 	RemoveServers(...string) error
 
+	// DrainServers stops selecting one or more servers, waits for their
+	// in-flight requests to complete, then removes them from the load balancer
+	// and stops their health checks.
+	DrainServers(context.Context, ...string) error
+
 	// Servers returns a list of all servers in the load balancer.
 	Servers() []*proxy.Proxy
 
-	// RemoveAll removes all servers from the load balancer.
+	// RemoveAll removes all servers from the load balancer
+	// and stops their health checks.
 	RemoveAll()
 }
 
@@ -37,6 +53,18 @@ type roundrobin struct {
 	sync.RWMutex
 	servers []*proxy.Proxy
 	next    uint32
+
+	// healthyOnly skips servers that are not available.
+	healthyOnly bool
+	// includeUnknown treats servers with an unknown health state as available.
+	includeUnknown bool
+
+	// slowStart ramps up the share of newly added or recovered servers.
+	slowStart *slowstart.SlowStart
+	// warm holds the servers in their slow start window.
+	warm map[*proxy.Proxy]*warmup
+	// unwatch holds the functions to stop watching the health of the servers.
+	unwatch map[*proxy.Proxy]func()
 }
 
 // NextServer returns the next server in the round-robin algorithm.
@@ -44,7 +72,14 @@ type roundrobin struct {
 // The server selection is based on an atomic counter that increments with each call.
 // The selected server is determined by calculating the index using the modulo operation.
 // This method is thread-safe using atomic operations and read locks.
+// With WithHealthyOnly, unavailable servers are skipped and nil is returned
+// if no server is available. With WithSlowStart, servers in their slow start
+// window receive a reduced share of the requests.
 func (r *roundrobin) NextServer() *proxy.Proxy {
+	if r.slowStart != nil && r.warming() {
+		return r.nextWarmServer()
+	}
+
 	index := atomic.AddUint32(&r.next, 1)
 
 	r.RLock()
@@ -53,22 +88,49 @@ func (r *roundrobin) NextServer() *proxy.Proxy {
 		r.RUnlock()
 		return nil
 	}
-	server := r.servers[(index-1)%count]
+	if !r.healthyOnly {
+		server := r.servers[(index-1)%count]
+		r.RUnlock()
+		return server
+	}
+	for i := uint32(0); i < count; i++ {
+		server := r.servers[(index-1+i)%count]
+		if r.available(server) {
+			r.RUnlock()
+			return server
+		}
+	}
 	r.RUnlock()
-	return server
+	return nil
+}
+
+// available reports whether the server can be selected.
+func (r *roundrobin) available(server *proxy.Proxy) bool {
+	switch server.State() {
+	case health.StateHealthy:
+		return true
+	case health.StateUnknown:
+		return r.includeUnknown
+	default:
+		return false
+	}
 }
 
 // AddServers adds the given servers to the roundrobin load balancer.
 // It takes a variadic parameter of type *proxy.Proxy, representing the servers to be added.
 // If no servers are provided, it returns an error of type ErrServersEmpty.
 // The function uses a write lock to ensure thread safety while modifying the server list.
+// The health checks of the servers are started again if they were stopped by a removal.
 func (r *roundrobin) AddServers(servers ...*proxy.Proxy) error {
 	if len(servers) == 0 {
 		return ErrServersEmpty
 	}
 
+	startServers(servers)
+
 	r.Lock()
 	r.servers = append(r.servers, servers...)
+	r.watch(servers)
 	r.Unlock()
 	return nil
 }
@@ -78,19 +140,58 @@ func (r *roundrobin) AddServers(servers ...*proxy.Proxy) error {
 // If the 'names' parameter is empty, it returns an error of type 'ErrServersEmpty'.
 // The function is thread-safe and uses a write lock to prevent concurrent access.
 // It uses an optimized in-place removal algorithm to minimize memory allocations.
+// The health checks of the removed servers are stopped after the lock is released.
 func (r *roundrobin) RemoveServers(names ...string) error {
 	if len(names) == 0 {
 		return ErrServersEmpty
 	}
 
 	r.Lock()
-	defer r.Unlock()
+	removed := r.removeServers(names)
+	r.forget(removed)
+	r.Unlock()
+
+	closeServers(removed)
+	return nil
+}
+
+// DrainServers gracefully removes the specified servers from the roundrobin load balancer.
+// The servers are no longer selected once DrainServers is called. It then waits until the
+// servers have no in-flight requests or the context is done, and stops their health checks.
+// If the context is done before the servers are drained, the servers are removed anyway
+// and the context error is returned.
+func (r *roundrobin) DrainServers(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return ErrServersEmpty
+	}
+
+	r.Lock()
+	removed := r.removeServers(names)
+	r.forget(removed)
+	r.Unlock()
+
+	var err error
+	for _, s := range removed {
+		if drainErr := s.Drain(ctx); drainErr != nil && err == nil {
+			err = drainErr
+		}
+	}
+
+	closeServers(removed)
+	return err
+}
+
+// removeServers removes the servers with the given names and returns them.
+// The caller must hold the write lock.
+func (r *roundrobin) removeServers(names []string) []*proxy.Proxy {
+	var removed []*proxy.Proxy
 
 	// For small number of names, use linear search to avoid map allocation
 	if len(names) <= 2 {
 		for _, name := range names {
 			for i := 0; i < len(r.servers); i++ {
 				if r.servers[i].GetName() == name {
+					removed = append(removed, r.servers[i])
 					// Remove by swapping with last element and truncating
 					r.servers[i] = r.servers[len(r.servers)-1]
 					r.servers = r.servers[:len(r.servers)-1]
@@ -99,7 +200,7 @@ func (r *roundrobin) RemoveServers(names ...string) error {
 				}
 			}
 		}
-		return nil
+		return removed
 	}
 
 	// For larger number of names, use map for better performance
@@ -114,6 +215,8 @@ func (r *roundrobin) RemoveServers(names ...string) error {
 		if _, exists := nameMap[r.servers[readIndex].GetName()]; !exists {
 			r.servers[writeIndex] = r.servers[readIndex]
 			writeIndex++
+		} else {
+			removed = append(removed, r.servers[readIndex])
 		}
 	}
 
@@ -122,7 +225,7 @@ func (r *roundrobin) RemoveServers(names ...string) error {
 		r.servers[i] = nil
 	}
 	r.servers = r.servers[:writeIndex]
-	return nil
+	return removed
 }
 
 // Servers returns a copy of all servers in the roundrobin load balancer.
@@ -138,23 +241,63 @@ func (r *roundrobin) Servers() []*proxy.Proxy {
 }
 
 // RemoveAll removes all servers from the roundrobin load balancer.
-// It resets both the server list and the rotation counter atomically.
+// It resets both the server list and the rotation counter atomically,
+// and stops the health checks of the removed servers.
 func (r *roundrobin) RemoveAll() {
 	r.Lock()
+	removed := make([]*proxy.Proxy, len(r.servers))
+	copy(removed, r.servers)
+	r.forget(removed)
+	for i := range r.servers {
+		r.servers[i] = nil
+	}
 	r.servers = r.servers[:0]
 	r.Unlock()
 	atomic.StoreUint32(&r.next, 0)
+
+	closeServers(removed)
+}
+
+// startServers starts the health checks of the given servers.
+func startServers(servers []*proxy.Proxy) {
+	for _, s := range servers {
+		s.Start()
+	}
+}
+
+// closeServers stops the health checks of the given servers.
+func closeServers(servers []*proxy.Proxy) {
+	for _, s := range servers {
+		_ = s.Close()
+	}
 }
 
 // New creates a new instance of the round-robin load balancer with the specified servers.
 // If no servers are provided, it creates an empty load balancer that can have servers added later.
 func New(servers ...*proxy.Proxy) (RoundRobin, error) {
+	return NewWithOptions(servers)
+}
+
+// NewWithOptions creates a new instance of the round-robin load balancer
+// with the specified servers and options.
+func NewWithOptions(servers []*proxy.Proxy, opts ...Opts) (RoundRobin, error) {
 	rb := &roundrobin{
 		servers: make([]*proxy.Proxy, len(servers)),
 	}
 
 	// Copy servers to prevent external modifications
 	copy(rb.servers, servers)
+	startServers(servers)
+
+	for _, opt := range opts {
+		opt(rb)
+	}
+
+	if rb.slowStart != nil {
+		rb.warm = make(map[*proxy.Proxy]*warmup)
+		rb.unwatch = make(map[*proxy.Proxy]func())
+		rb.watch(rb.servers)
+	}
 
 	return rb, nil
 }
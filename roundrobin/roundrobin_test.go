@@ -1,11 +1,20 @@
 package roundrobin
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
 	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/slowstart"
 )
 
 func TestNextServer(t *testing.T) {
@@ -121,6 +130,55 @@ func TestRemoveServers(t *testing.T) {
 	}
 }
 
+func TestRemoveServersReAdd(t *testing.T) {
+	var down atomic.Bool
+	addr := &url.URL{Host: "s1"}
+	h, err := health.New(addr,
+		health.WithCheck(func(*url.URL) error {
+			if down.Load() {
+				return errors.New("down")
+			}
+			return nil
+		}),
+		health.WithPeriod(5*time.Millisecond),
+		health.WithSuccessThreshold(1),
+		health.WithFailureThreshold(1),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create health check: %v", err)
+	}
+	s1 := proxy.NewProxy("s1", addr, proxy.WithHealth(h))
+	defer s1.Close()
+
+	waitState := func(want health.State) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for s1.State() != want {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected state %v, but got %v", want, s1.State())
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	r, _ := New(s1)
+	waitState(health.StateHealthy)
+
+	// The health check of a removed server is stopped.
+	_ = r.RemoveServers("s1")
+	down.Store(true)
+	time.Sleep(50 * time.Millisecond)
+	if got := s1.State(); got != health.StateHealthy {
+		t.Fatalf("Expected the removed server to keep its last state, but got %v", got)
+	}
+
+	// It is checked again once added back.
+	_ = r.AddServers(s1)
+	waitState(health.StateUnhealthy)
+	down.Store(false)
+	waitState(health.StateHealthy)
+}
+
 func BenchmarkNext(b *testing.B) {
 	servers := []*proxy.Proxy{
 		proxy.NewProxy("s1", &url.URL{Host: "192.168.1.10"}),
@@ -250,3 +308,217 @@ func ExampleRoundRobin() {
 	// s3
 	// d1
 }
+
+func TestNextServerHealthyOnly(t *testing.T) {
+	servers := []*proxy.Proxy{
+		proxytest.NewProxy(t, "healthy", health.StateHealthy),
+		proxytest.NewProxy(t, "unhealthy", health.StateUnhealthy),
+		proxytest.NewProxy(t, "unknown", health.StateUnknown),
+	}
+
+	tests := []struct {
+		name           string
+		includeUnknown bool
+		want           map[string]bool
+	}{
+		{
+			name:           "exclude unknown",
+			includeUnknown: false,
+			want:           map[string]bool{"healthy": true},
+		},
+		{
+			name:           "include unknown",
+			includeUnknown: true,
+			want:           map[string]bool{"healthy": true, "unknown": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := NewWithOptions(servers, WithHealthyOnly(tt.includeUnknown))
+			seen := map[string]bool{}
+			for i := 0; i < 6; i++ {
+				seen[r.NextServer().GetName()] = true
+			}
+			if len(seen) != len(tt.want) {
+				t.Fatalf("Expected servers %v, but got %v", tt.want, seen)
+			}
+			for name := range seen {
+				if !tt.want[name] {
+					t.Fatalf("Unexpected server %s selected", name)
+				}
+			}
+		})
+	}
+
+	r, _ := NewWithOptions(servers[1:2], WithHealthyOnly(true))
+	if s := r.NextServer(); s != nil {
+		t.Fatalf("Expected no server, but got %s", s.GetName())
+	}
+}
+
+func TestNextServerSlowStart(t *testing.T) {
+	s, err := slowstart.New(200 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create slow start: %v", err)
+	}
+
+	servers := []*proxy.Proxy{
+		proxy.NewProxy("s1", &url.URL{Host: "192.168.1.10"}),
+		proxy.NewProxy("s2", &url.URL{Host: "192.168.1.11"}),
+	}
+	r, _ := NewWithOptions(servers, WithSlowStart(s))
+	defer r.RemoveAll()
+
+	// The servers the balancer is created with warm up too.
+	if !r.(*roundrobin).warming() {
+		t.Fatal("Expected the initial servers to warm up")
+	}
+	time.Sleep(250 * time.Millisecond)
+
+	if err := r.AddServers(proxy.NewProxy("d1", &url.URL{Host: "192.168.2.10"})); err != nil {
+		t.Fatalf("Failed to add servers: %v", err)
+	}
+
+	count := func() int {
+		n := 0
+		for i := 0; i < 300; i++ {
+			if r.NextServer().GetName() == "d1" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// d1 receives only a fraction of its share while warming up.
+	if n := count(); n >= 50 {
+		t.Fatalf("Expected d1 to receive a reduced share while warming up, but got %d/300", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	// d1 receives its full share after the slow start window.
+	if n := count(); n != 100 {
+		t.Fatalf("Expected d1 to receive 100/300 after warming up, but got %d", n)
+	}
+}
+
+func TestNextServerSlowStartRecovery(t *testing.T) {
+	s, _ := slowstart.New(time.Hour)
+
+	var failing atomic.Bool
+	addr := &url.URL{Host: "192.168.1.10"}
+	h, _ := health.New(addr,
+		health.WithCheck(func(*url.URL) error {
+			if failing.Load() {
+				return errors.New("down")
+			}
+			return nil
+		}),
+		health.WithPeriod(5*time.Millisecond),
+		health.WithInitialDelay(20*time.Millisecond),
+		health.WithFailureThreshold(1),
+	)
+	server := proxy.NewProxy("s1", addr, proxy.WithHealth(h))
+
+	r, _ := NewWithOptions([]*proxy.Proxy{server}, WithSlowStart(s))
+	defer r.RemoveAll()
+	rr := r.(*roundrobin)
+
+	// End the warm up of the server the balancer is created with.
+	rr.Lock()
+	rr.warm[server].since = time.Now().Add(-time.Hour)
+	rr.Unlock()
+	r.NextServer()
+
+	// Subscribed after the balancer, so the events reach the balancer first.
+	states, unsubscribe := server.Subscribe(10)
+	defer unsubscribe()
+
+	wait := func(want health.State) {
+		t.Helper()
+		for {
+			select {
+			case e := <-states:
+				if e.To == want {
+					return
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Timed out waiting for state %s", want)
+			}
+		}
+	}
+
+	// The first result of a server is not a recovery.
+	wait(health.StateHealthy)
+	if rr.warming() {
+		t.Fatal("Expected no slow start after the first healthy result")
+	}
+
+	failing.Store(true)
+	wait(health.StateUnhealthy)
+	failing.Store(false)
+	wait(health.StateHealthy)
+
+	if !rr.warming() {
+		t.Fatal("Expected the server to warm up after recovering")
+	}
+}
+
+func TestDrainServers(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	// s1 must not send health check requests to the test handler.
+	addr, _ := url.Parse(ts.URL)
+	s1 := proxytest.NewProxyWithAddr(t, "s1", addr, health.StateUnknown)
+	s2 := proxy.NewProxy("s2", &url.URL{Host: "192.168.1.11"})
+	r, _ := New(s1, s2)
+	defer r.RemoveAll()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		s1.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	// The drain times out while the request is in flight.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := r.DrainServers(ctx, "s1"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DrainServers() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if servers := r.Servers(); len(servers) != 1 || servers[0] != s2 {
+		t.Fatalf("Expected only s2 after drain, but got %d servers", len(servers))
+	}
+
+	// The drain completes once the in-flight request is done.
+	_ = r.AddServers(s1)
+	drained := make(chan error, 1)
+	go func() {
+		drained <- r.DrainServers(context.Background(), "s1")
+	}()
+
+	select {
+	case err := <-drained:
+		t.Fatalf("DrainServers() returned %v before the request completed", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-done
+	if err := <-drained; err != nil {
+		t.Fatalf("DrainServers() error = %v", err)
+	}
+	if s1.GetLoading() != 0 {
+		t.Fatalf("Expected no in-flight requests, but got %d", s1.GetLoading())
+	}
+}
@@ -0,0 +1,116 @@
+package roundrobin
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+)
+
+// warmup tracks a server in its slow start window.
+type warmup struct {
+	// since is the time the server was added or became healthy again.
+	since time.Time
+	// credit accumulates the effective weight of the server;
+	// the server is selected each time it reaches 1.
+	credit float64
+}
+
+// watch starts tracking the servers for slow start.
+// The servers start warming up immediately, including the servers the
+// load balancer is created with, and every server warms up again when it
+// recovers from unhealthy. The caller must hold the write lock.
+func (r *roundrobin) watch(servers []*proxy.Proxy) {
+	if r.slowStart == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, server := range servers {
+		r.warm[server] = &warmup{since: now}
+		if _, ok := r.unwatch[server]; ok {
+			continue
+		}
+
+		server := server
+		r.unwatch[server] = server.OnStatusChange(func(e health.Event) {
+			// The first result of a new server is not a recovery.
+			if e.From != health.StateUnhealthy || e.To != health.StateHealthy {
+				return
+			}
+			r.Lock()
+			if _, ok := r.unwatch[server]; ok {
+				r.warm[server] = &warmup{since: e.Time}
+			}
+			r.Unlock()
+		})
+	}
+}
+
+// forget stops tracking the servers for slow start.
+// The caller must hold the write lock.
+func (r *roundrobin) forget(servers []*proxy.Proxy) {
+	if r.slowStart == nil {
+		return
+	}
+
+	for _, server := range servers {
+		if unwatch, ok := r.unwatch[server]; ok {
+			unwatch()
+			delete(r.unwatch, server)
+		}
+		delete(r.warm, server)
+	}
+}
+
+// warming reports whether a server is in its slow start window.
+func (r *roundrobin) warming() bool {
+	r.RLock()
+	defer r.RUnlock()
+	return len(r.warm) > 0
+}
+
+// nextWarmServer returns the next server in the rotation, taking the slow start into account.
+// A server in its slow start window is only selected for the fraction of its turns given
+// by its effective weight; the remaining turns go to the following servers.
+func (r *roundrobin) nextWarmServer() *proxy.Proxy {
+	index := atomic.AddUint32(&r.next, 1)
+	now := time.Now()
+
+	r.Lock()
+	defer r.Unlock()
+
+	var fallback *proxy.Proxy
+	count := uint32(len(r.servers))
+	for i := uint32(0); i < count; i++ {
+		server := r.servers[(index-1+i)%count]
+		if r.healthyOnly && !r.available(server) {
+			continue
+		}
+
+		w, ok := r.warm[server]
+		if !ok {
+			return server
+		}
+
+		factor := r.slowStart.Factor(w.since, now)
+		if factor >= 1 {
+			delete(r.warm, server)
+			return server
+		}
+
+		w.credit += factor
+		if w.credit >= 1 {
+			w.credit--
+			return server
+		}
+
+		if fallback == nil {
+			fallback = server
+		}
+	}
+
+	// Every available server is warming up and none has enough credit.
+	return fallback
+}
@@ -0,0 +1,28 @@
+package router
+
+import "github.com/appleboy/loadbalancer-algorithms/proxy"
+
+// Opts configures a Router.
+type Opts func(*Router)
+
+// WithPool adds a named pool of servers, such as a roundrobin.RoundRobin.
+func WithPool(name string, balancer proxy.Balancer) Opts {
+	return func(r *Router) {
+		r.pools[name] = balancer
+	}
+}
+
+// WithRoute adds a route to a pool added with WithPool.
+func WithRoute(route Route) Opts {
+	return func(r *Router) {
+		r.routes = append(r.routes, route)
+	}
+}
+
+// WithDefaultPool sets the pool serving the requests matching no route.
+// By default these requests are answered with 404 Not Found.
+func WithDefaultPool(name string) Opts {
+	return func(r *Router) {
+		r.defaultPool = name
+	}
+}
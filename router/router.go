@@ -0,0 +1,217 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+var (
+	// ErrUnknownPool is returned when a route or the default pool refers to a pool that does not exist.
+	ErrUnknownPool = errors.New("router: unknown pool")
+	// ErrInvalidHost is returned when a host pattern is malformed.
+	ErrInvalidHost = errors.New("router: host must be a name or a *.suffix wildcard")
+	// ErrInvalidPrefix is returned when a path prefix or its rewrite does not start with a slash.
+	ErrInvalidPrefix = errors.New("router: path prefix must start with /")
+)
+
+// Route maps the requests matching a host pattern and a path prefix to a pool.
+type Route struct {
+	// Host is the host of the requests, without port. It is either a name,
+	// such as api.example.com, or a wildcard such as *.example.com matching
+	// any subdomain. An empty host matches any host.
+	Host string
+	// PathPrefix is the prefix of the request path, matched on path segments:
+	// /api matches /api and /api/users but not /apis. An empty prefix matches any path.
+	PathPrefix string
+	// Pool is the name of the pool serving the requests.
+	Pool string
+	// StripPrefix removes the path prefix before forwarding the request.
+	StripPrefix bool
+	// RewritePrefix replaces the path prefix before forwarding the request,
+	// for example /v2 to forward /api/users to /v2/users.
+	RewritePrefix string
+}
+
+// rewrites reports whether the route changes the request path.
+func (r *Route) rewrites() bool {
+	return r.StripPrefix || r.RewritePrefix != ""
+}
+
+// Router forwards the requests to named pools of servers, each being its own
+// load balancer, based on their host and path. Routes with an exact host come
+// first, then wildcard hosts from the longest suffix, then routes matching any
+// host. Among them, the longest matching path prefix wins. The requests
+// matching no route go to the default pool.
+type Router struct {
+	pools       map[string]proxy.Balancer
+	routes      []Route
+	defaultPool string
+}
+
+// New creates a router with the pools and routes set by the options.
+func New(opts ...Opts) (*Router, error) {
+	r := &Router{
+		pools: make(map[string]proxy.Balancer),
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	for i := range r.routes {
+		route := &r.routes[i]
+		route.Host = strings.ToLower(route.Host)
+		if err := validate(route); err != nil {
+			return nil, err
+		}
+		if _, ok := r.pools[route.Pool]; !ok {
+			return nil, ErrUnknownPool
+		}
+	}
+
+	if _, ok := r.pools[r.defaultPool]; r.defaultPool != "" && !ok {
+		return nil, ErrUnknownPool
+	}
+
+	// Stable so that routes of the same specificity keep their order.
+	sort.SliceStable(r.routes, func(i, j int) bool {
+		hi, hj := hostRank(r.routes[i].Host), hostRank(r.routes[j].Host)
+		if hi != hj {
+			return hi > hj
+		}
+		return len(r.routes[i].PathPrefix) > len(r.routes[j].PathPrefix)
+	})
+
+	return r, nil
+}
+
+// validate checks the host pattern and the path prefixes of the route.
+func validate(route *Route) error {
+	if strings.Contains(route.Host, "*") && (!strings.HasPrefix(route.Host, "*.") ||
+		len(route.Host) == 2 || strings.Contains(route.Host[1:], "*")) {
+		return ErrInvalidHost
+	}
+	if route.PathPrefix != "" && !strings.HasPrefix(route.PathPrefix, "/") {
+		return ErrInvalidPrefix
+	}
+	if route.RewritePrefix != "" && !strings.HasPrefix(route.RewritePrefix, "/") {
+		return ErrInvalidPrefix
+	}
+	return nil
+}
+
+// hostRank orders the host patterns from the most specific.
+func hostRank(host string) int {
+	switch {
+	case host == "":
+		return 0
+	case strings.HasPrefix(host, "*."):
+		// Longer suffixes are more specific, and always below exact hosts.
+		return 1 + len(host)
+	default:
+		return 1 << 16
+	}
+}
+
+// Pool returns the load balancer of the pool.
+func (r *Router) Pool(name string) (proxy.Balancer, bool) {
+	b, ok := r.pools[name]
+	return b, ok
+}
+
+// Match returns the route matching the request, or false if no route matches.
+func (r *Router) Match(req *http.Request) (Route, bool) {
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, route := range r.routes {
+		if matchHost(route.Host, host) && matchPrefix(route.PathPrefix, req.URL.Path) {
+			return route, true
+		}
+	}
+	return Route{}, false
+}
+
+// ServeHTTP forwards the request to a server of the pool it is routed to.
+// It responds with 404 Not Found if no route matches and there is no default
+// pool, and with 503 Service Unavailable if the pool has no server available.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	pool := r.defaultPool
+	route, ok := r.Match(req)
+	if ok {
+		pool = route.Pool
+	}
+
+	if pool == "" {
+		http.NotFound(w, req)
+		return
+	}
+
+	server := r.pools[pool].NextServer()
+	if server == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	if ok && route.rewrites() {
+		req = rewrite(req, route)
+	}
+	server.ServeHTTP(w, req)
+}
+
+// matchHost reports whether the host matches the pattern.
+func matchHost(pattern, host string) bool {
+	switch {
+	case pattern == "":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return len(host) > len(pattern)-1 && strings.HasSuffix(host, pattern[1:])
+	default:
+		return pattern == host
+	}
+}
+
+// matchPrefix reports whether the path starts with the prefix on a segment boundary.
+func matchPrefix(prefix, path string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// rewrite returns a shallow copy of the request with the path prefix of the
+// route stripped or replaced, like http.StripPrefix.
+func rewrite(req *http.Request, route Route) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.URL = new(url.URL)
+	*r.URL = *req.URL
+
+	r.URL.Path = replacePrefix(req.URL.Path, route.PathPrefix, route.RewritePrefix)
+	if req.URL.RawPath != "" {
+		r.URL.RawPath = replacePrefix(req.URL.RawPath, route.PathPrefix, route.RewritePrefix)
+	}
+	return r
+}
+
+// replacePrefix replaces the prefix of the path, keeping the result rooted.
+func replacePrefix(path, prefix, replacement string) string {
+	rest := strings.TrimPrefix(path, prefix)
+	if rest != "" && !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+
+	path = strings.TrimSuffix(replacement, "/") + rest
+	if path == "" {
+		return "/"
+	}
+	return path
+}
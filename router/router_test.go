@@ -0,0 +1,116 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// newPool returns a pool with one server answering with its name and the request path.
+func newPool(t *testing.T, name string) roundrobin.RoundRobin {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(name + " " + r.URL.Path))
+	}))
+	t.Cleanup(ts.Close)
+	addr, _ := url.Parse(ts.URL)
+
+	pool, _ := roundrobin.New(proxy.NewProxy(name, addr))
+	t.Cleanup(pool.RemoveAll)
+	return pool
+}
+
+func TestNew(t *testing.T) {
+	pool, _ := roundrobin.New()
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "unknown pool", opts: []Opts{WithRoute(Route{Pool: "api"})}, err: ErrUnknownPool},
+		{name: "unknown default pool", opts: []Opts{WithDefaultPool("api")}, err: ErrUnknownPool},
+		{name: "invalid host", opts: []Opts{WithPool("api", pool), WithRoute(Route{Host: "api.*.com", Pool: "api"})}, err: ErrInvalidHost},
+		{name: "invalid prefix", opts: []Opts{WithPool("api", pool), WithRoute(Route{PathPrefix: "api", Pool: "api"})}, err: ErrInvalidPrefix},
+		{name: "valid", opts: []Opts{WithPool("api", pool), WithRoute(Route{Host: "*.example.com", Pool: "api"})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRouter_ServeHTTP(t *testing.T) {
+	empty, _ := roundrobin.New()
+	r, err := New(
+		WithPool("web", newPool(t, "web")),
+		WithPool("api", newPool(t, "api")),
+		WithPool("users", newPool(t, "users")),
+		WithPool("admin", newPool(t, "admin")),
+		WithPool("tenant", newPool(t, "tenant")),
+		WithPool("empty", empty),
+		WithDefaultPool("web"),
+		WithRoute(Route{PathPrefix: "/api", Pool: "api", StripPrefix: true}),
+		WithRoute(Route{PathPrefix: "/api/users", Pool: "users", RewritePrefix: "/v2/users"}),
+		WithRoute(Route{Host: "admin.example.com", Pool: "admin"}),
+		WithRoute(Route{Host: "*.example.com", PathPrefix: "/app", Pool: "tenant"}),
+		WithRoute(Route{PathPrefix: "/down", Pool: "empty"}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		code   int
+		want   string
+	}{
+		{name: "default pool", target: "http://example.com/index.html", code: http.StatusOK, want: "web /index.html"},
+		{name: "strip prefix", target: "http://example.com/api/orders", code: http.StatusOK, want: "api /orders"},
+		{name: "strip whole path", target: "http://example.com/api", code: http.StatusOK, want: "api /"},
+		{name: "segment boundary", target: "http://example.com/apis", code: http.StatusOK, want: "web /apis"},
+		{name: "longest prefix", target: "http://example.com/api/users/1", code: http.StatusOK, want: "users /v2/users/1"},
+		{name: "exact host", target: "http://admin.example.com:8080/api/orders", code: http.StatusOK, want: "admin /api/orders"},
+		{name: "wildcard host", target: "http://acme.example.com/app/home", code: http.StatusOK, want: "tenant /app/home"},
+		{name: "wildcard host without subdomain", target: "http://example.com/app/home", code: http.StatusOK, want: "web /app/home"},
+		{name: "no server", target: "http://example.com/down", code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != tt.code {
+				t.Fatalf("ServeHTTP() status = %d, want %d", rec.Code, tt.code)
+			}
+			if tt.want != "" && rec.Body.String() != tt.want {
+				t.Errorf("ServeHTTP() body = %q, want %q", rec.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_NotFound(t *testing.T) {
+	r, _ := New(
+		WithPool("api", newPool(t, "api")),
+		WithRoute(Route{PathPrefix: "/api", Pool: "api"}),
+	)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("ServeHTTP() status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
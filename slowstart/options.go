@@ -0,0 +1,19 @@
+package slowstart
+
+// Opts configures a SlowStart.
+type Opts func(*SlowStart)
+
+// WithMinWeight sets the fraction of the full weight a server starts with.
+// It must be in the range (0, 1]. Defaults to 0.1.
+func WithMinWeight(minWeight float64) Opts {
+	return func(s *SlowStart) {
+		s.minWeight = minWeight
+	}
+}
+
+// WithCurve sets the curve used to ramp up the weight. Defaults to Linear.
+func WithCurve(curve Curve) Opts {
+	return func(s *SlowStart) {
+		s.curve = curve
+	}
+}
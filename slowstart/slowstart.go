@@ -0,0 +1,85 @@
+package slowstart
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+var (
+	// ErrInvalidWindow is returned when the slow start window is not positive.
+	ErrInvalidWindow = errors.New("slowstart: window must be greater than zero")
+	// ErrInvalidMinWeight is returned when the minimum weight is not in the range (0, 1].
+	ErrInvalidMinWeight = errors.New("slowstart: min weight must be in the range (0, 1]")
+)
+
+// Default fraction of the full weight a server starts with.
+const defaultMinWeight = 0.1
+
+// Curve maps the progress through the slow start window, in the range [0, 1],
+// to a fraction of the full weight, in the range [0, 1].
+type Curve func(progress float64) float64
+
+// Linear ramps the weight up linearly over the window.
+func Linear(progress float64) float64 {
+	return progress
+}
+
+// Aggression returns a curve that ramps the weight up as progress^(1/aggression).
+// An aggression greater than 1 ramps up faster at the beginning of the window,
+// an aggression less than 1 ramps up slower. An aggression of 1 is Linear.
+func Aggression(aggression float64) Curve {
+	return func(progress float64) float64 {
+		return math.Pow(progress, 1/aggression)
+	}
+}
+
+// SlowStart ramps the effective weight of a newly added or recovered server
+// from a small fraction to its full weight over a window.
+type SlowStart struct {
+	window    time.Duration
+	minWeight float64
+	curve     Curve
+}
+
+// New creates a slow start that ramps up the weight over the given window.
+func New(window time.Duration, opts ...Opts) (*SlowStart, error) {
+	s := &SlowStart{
+		window:    window,
+		minWeight: defaultMinWeight,
+		curve:     Linear,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.window <= 0 {
+		return nil, ErrInvalidWindow
+	}
+	if s.minWeight <= 0 || s.minWeight > 1 {
+		return nil, ErrInvalidMinWeight
+	}
+
+	return s, nil
+}
+
+// Window returns the duration of the slow start.
+func (s *SlowStart) Window() time.Duration {
+	return s.window
+}
+
+// Factor returns the fraction of the full weight, in the range [min weight, 1],
+// for a server that started warming up at since.
+func (s *SlowStart) Factor(since, now time.Time) float64 {
+	elapsed := now.Sub(since)
+	if elapsed >= s.window {
+		return 1
+	}
+	if elapsed <= 0 {
+		return s.minWeight
+	}
+
+	f := s.curve(float64(elapsed) / float64(s.window))
+	return math.Min(1, math.Max(s.minWeight, f))
+}
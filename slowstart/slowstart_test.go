@@ -0,0 +1,63 @@
+package slowstart
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	if _, err := New(0); !errors.Is(err, ErrInvalidWindow) {
+		t.Errorf("New(0) error = %v, want %v", err, ErrInvalidWindow)
+	}
+	if _, err := New(time.Second, WithMinWeight(0)); !errors.Is(err, ErrInvalidMinWeight) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidMinWeight)
+	}
+	if _, err := New(time.Second, WithMinWeight(1.5)); !errors.Is(err, ErrInvalidMinWeight) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidMinWeight)
+	}
+}
+
+func TestSlowStart_Factor(t *testing.T) {
+	since := time.Now()
+
+	tests := []struct {
+		name    string
+		opts    []Opts
+		elapsed time.Duration
+		want    float64
+	}{
+		{name: "start", elapsed: 0, want: 0.1},
+		{name: "before min weight", elapsed: time.Second, want: 0.1},
+		{name: "half way", elapsed: 5 * time.Second, want: 0.5},
+		{name: "end of window", elapsed: 10 * time.Second, want: 1},
+		{name: "after window", elapsed: time.Minute, want: 1},
+		{
+			name:    "custom min weight",
+			opts:    []Opts{WithMinWeight(0.3)},
+			elapsed: 2 * time.Second,
+			want:    0.3,
+		},
+		{
+			name:    "aggression curve",
+			opts:    []Opts{WithCurve(Aggression(2))},
+			elapsed: 2500 * time.Millisecond,
+			want:    0.5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(10*time.Second, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create slow start: %v", err)
+			}
+
+			got := s.Factor(since, since.Add(tt.elapsed))
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Factor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
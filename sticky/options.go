@@ -0,0 +1,59 @@
+package sticky
+
+import (
+	"net/http"
+	"time"
+)
+
+// Opts configures a Sticky.
+type Opts func(*Sticky)
+
+// WithCookieName sets the name of the affinity cookie. Defaults to "lb_sticky".
+func WithCookieName(name string) Opts {
+	return func(s *Sticky) {
+		s.cookieName = name
+	}
+}
+
+// WithTTL sets the lifetime of the affinity cookie, after which it is ignored.
+// Defaults to 0, which creates a session cookie.
+func WithTTL(ttl time.Duration) Opts {
+	return func(s *Sticky) {
+		s.ttl = ttl
+	}
+}
+
+// WithSecure sets the Secure flag of the affinity cookie.
+func WithSecure(secure bool) Opts {
+	return func(s *Sticky) {
+		s.secure = secure
+	}
+}
+
+// WithHTTPOnly sets the HttpOnly flag of the affinity cookie. Defaults to true.
+func WithHTTPOnly(httpOnly bool) Opts {
+	return func(s *Sticky) {
+		s.httpOnly = httpOnly
+	}
+}
+
+// WithSameSite sets the SameSite attribute of the affinity cookie. Defaults to Lax.
+func WithSameSite(sameSite http.SameSite) Opts {
+	return func(s *Sticky) {
+		s.sameSite = sameSite
+	}
+}
+
+// WithPath sets the path of the affinity cookie. Defaults to "/".
+func WithPath(path string) Opts {
+	return func(s *Sticky) {
+		s.path = path
+	}
+}
+
+// WithDomain sets the domain of the affinity cookie.
+func WithDomain(domain string) Opts {
+	return func(s *Sticky) {
+		s.domain = domain
+	}
+}
@@ -0,0 +1,197 @@
+package sticky
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+// ErrSecretEmpty is returned when no secret is provided to sign the cookies.
+var ErrSecretEmpty = errors.New("sticky: secret is empty")
+
+// Default name of the session affinity cookie.
+const defaultCookieName = "lb_sticky"
+
+// Sticky adds cookie based session affinity on top of a load balancer.
+//
+// The first request of a client is routed to the server selected by the
+// underlying balancer, and a signed cookie naming that server is set.
+// Subsequent requests carrying the cookie are routed to the same server
+// as long as it is available, otherwise a new server is selected and the
+// cookie is updated. With a TTL, the expiry time is part of the signed
+// cookie value, so an expired cookie is ignored even if the client keeps it.
+type Sticky struct {
+	balancer proxy.Balancer
+	secret   []byte
+
+	cookieName string
+	path       string
+	domain     string
+	ttl        time.Duration
+	secure     bool
+	httpOnly   bool
+	sameSite   http.SameSite
+}
+
+// New creates a sticky session layer on top of the balancer.
+// The secret is used to sign the cookies, so that clients cannot pick a server.
+func New(balancer proxy.Balancer, secret []byte, opts ...Opts) (*Sticky, error) {
+	if len(secret) == 0 {
+		return nil, ErrSecretEmpty
+	}
+
+	s := &Sticky{
+		balancer:   balancer,
+		secret:     secret,
+		cookieName: defaultCookieName,
+		path:       "/",
+		httpOnly:   true,
+		sameSite:   http.SameSiteLaxMode,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// NextServer returns the server the request is bound to if it is available,
+// otherwise the next server of the underlying balancer. The affinity cookie is
+// set on w whenever a new server is selected. It returns nil if no server is available.
+func (s *Sticky) NextServer(w http.ResponseWriter, r *http.Request) *proxy.Proxy {
+	if server := s.boundServer(r); server != nil {
+		return server
+	}
+
+	server := s.balancer.NextServer()
+	if server == nil {
+		return nil
+	}
+
+	http.SetCookie(w, s.cookie(server.GetName()))
+	return server
+}
+
+// ServeHTTP forwards the request to the server selected by NextServer.
+// It responds with 503 Service Unavailable if no server is available.
+func (s *Sticky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := s.NextServer(w, r)
+	if server == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// boundServer returns the available server named by the affinity cookie, if any.
+func (s *Sticky) boundServer(r *http.Request) *proxy.Proxy {
+	c, err := r.Cookie(s.cookieName)
+	if err != nil {
+		return nil
+	}
+
+	name, ok := s.verify(c.Value)
+	if !ok {
+		return nil
+	}
+
+	for _, server := range s.balancer.Servers() {
+		if server.GetName() == name {
+			if server.IsAvailable() {
+				return server
+			}
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// cookie returns the affinity cookie for the server name.
+func (s *Sticky) cookie(name string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     s.cookieName,
+		Value:    s.sign(name),
+		Path:     s.path,
+		Domain:   s.domain,
+		Secure:   s.secure,
+		HttpOnly: s.httpOnly,
+		SameSite: s.sameSite,
+	}
+
+	if s.ttl > 0 {
+		c.MaxAge = int(s.ttl / time.Second)
+		c.Expires = time.Now().Add(s.ttl)
+	}
+
+	return c
+}
+
+// sign returns the cookie value for the server name: the encoded name and
+// the expiry time of the cookie, followed by their HMAC-SHA256 signature.
+// Without TTL the expiry is 0 and the value does not expire.
+func (s *Sticky) sign(name string) string {
+	var expires time.Time
+	if s.ttl > 0 {
+		expires = time.Now().Add(s.ttl)
+	}
+	return s.signAt(name, expires)
+}
+
+// signAt returns the cookie value for the server name expiring at the given time,
+// or never if it is zero.
+func (s *Sticky) signAt(name string, expires time.Time) string {
+	var unix int64
+	if !expires.IsZero() {
+		unix = expires.Unix()
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(name)) + "." + strconv.FormatInt(unix, 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.mac(payload))
+}
+
+// verify checks the signature and the expiry time of the cookie value
+// and returns the server name.
+func (s *Sticky) verify(value string) (string, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return "", false
+	}
+	payload, signature := value[:i], value[i+1:]
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, s.mac(payload)) {
+		return "", false
+	}
+
+	encoded, expiry, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", false
+	}
+	unix, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || (unix != 0 && time.Now().Unix() >= unix) {
+		return "", false
+	}
+
+	name, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", false
+	}
+
+	return string(name), true
+}
+
+// mac returns the HMAC-SHA256 of the message.
+func (s *Sticky) mac(message string) []byte {
+	h := hmac.New(sha256.New, s.secret)
+	_, _ = h.Write([]byte(message))
+	return h.Sum(nil)
+}
@@ -0,0 +1,152 @@
+package sticky
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+func TestNew(t *testing.T) {
+	r, _ := roundrobin.New()
+	if _, err := New(r, nil); !errors.Is(err, ErrSecretEmpty) {
+		t.Fatalf("New() error = %v, want %v", err, ErrSecretEmpty)
+	}
+}
+
+func TestSticky_NextServer(t *testing.T) {
+	r, _ := roundrobin.NewWithOptions([]*proxy.Proxy{
+		proxytest.NewProxy(t, "s1", health.StateHealthy),
+		proxytest.NewProxy(t, "s2", health.StateHealthy),
+		proxytest.NewProxy(t, "s3", health.StateUnhealthy),
+	}, roundrobin.WithHealthyOnly(false))
+	s, err := New(r, []byte("secret"),
+		WithCookieName("affinity"),
+		WithTTL(time.Hour),
+		WithSecure(true),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create sticky: %v", err)
+	}
+
+	// The first request selects a server and sets the cookie.
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if got := s.NextServer(rec, req).GetName(); got != "s1" {
+		t.Fatalf("Expected s1, but got %s", got)
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected 1 cookie, but got %d", len(cookies))
+	}
+	cookie := cookies[0]
+	if cookie.Name != "affinity" || !cookie.Secure || !cookie.HttpOnly || cookie.MaxAge != 3600 {
+		t.Fatalf("unexpected cookie: %+v", cookie)
+	}
+
+	// Subsequent requests with the cookie stick to the same server.
+	for i := 0; i < 3; i++ {
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.AddCookie(cookie)
+		if got := s.NextServer(rec, req).GetName(); got != "s1" {
+			t.Fatalf("Expected s1, but got %s", got)
+		}
+		if len(rec.Result().Cookies()) != 0 {
+			t.Fatalf("Expected no new cookie for a bound request")
+		}
+	}
+
+	// A tampered cookie is ignored.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.AddCookie(&http.Cookie{Name: "affinity", Value: cookie.Value + "x"})
+	if got := s.NextServer(rec, req).GetName(); got != "s2" {
+		t.Fatalf("Expected s2, but got %s", got)
+	}
+
+	// A request bound to an unavailable server falls back to the balancer.
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	req.AddCookie(&http.Cookie{Name: "affinity", Value: s.sign("s3")})
+	got := s.NextServer(rec, req)
+	if got.GetName() == "s3" {
+		t.Fatalf("Expected fallback from unavailable s3")
+	}
+	cookies = rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Expected the cookie to be updated, but got %d cookies", len(cookies))
+	}
+	if name, ok := s.verify(cookies[0].Value); !ok || name != got.GetName() {
+		t.Fatalf("Expected cookie for %s, but got %q", got.GetName(), name)
+	}
+}
+
+func TestSticky_Verify(t *testing.T) {
+	r, _ := roundrobin.New()
+	s, _ := New(r, []byte("secret"), WithTTL(time.Hour))
+	other, _ := New(r, []byte("other"))
+
+	expired := s.signAt("s1", time.Now().Add(-time.Second))
+	// The signature covers the expiry time, which cannot be pushed back.
+	parts := strings.Split(expired, ".")
+	parts[1] = strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	extended := strings.Join(parts, ".")
+
+	tests := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{name: "valid", value: s.sign("s1"), ok: true},
+		{name: "no expiry", value: s.signAt("s1", time.Time{}), ok: true},
+		{name: "expired", value: expired},
+		{name: "extended expiry", value: extended},
+		{name: "other secret", value: other.sign("s1")},
+		{name: "malformed", value: "s1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, ok := s.verify(tt.value)
+			if ok != tt.ok || (ok && name != "s1") {
+				t.Errorf("verify() = %q, %v, want s1, %v", name, ok, tt.ok)
+			}
+		})
+	}
+}
+
+func TestSticky_ServeHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	r, _ := roundrobin.New()
+	s, _ := New(r, []byte("secret"))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+
+	addr, _ := url.Parse(ts.URL)
+	_ = r.AddServers(proxy.NewProxy("s1", addr))
+	defer r.RemoveAll()
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("Expected status code %d, got %d", http.StatusNoContent, rec.Code)
+	}
+}
@@ -0,0 +1,19 @@
+package subset
+
+// Opts configures a Subset.
+type Opts func(*Subset)
+
+// WithFactory sets the factory of the inner load balancers. Defaults to roundrobin.New.
+func WithFactory(factory Factory) Opts {
+	return func(s *Subset) {
+		s.factory = factory
+	}
+}
+
+// WithFallback sets the label selector of the subset used when the request
+// selects no subset or an empty one. Defaults to all servers.
+func WithFallback(selector map[string]string) Opts {
+	return func(s *Subset) {
+		s.fallback = selector
+	}
+}
@@ -0,0 +1,238 @@
+package subset
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// ErrSelectorEmpty is returned when no selector is provided.
+var ErrSelectorEmpty = errors.New("subset: selector is empty")
+
+// Selector returns the label selector of the request,
+// and false if the request does not select a subset.
+type Selector func(r *http.Request) (map[string]string, bool)
+
+// Header selects the servers whose label has the value of the request header,
+// for example Header("X-Version", "version") routes "X-Version: v2" to the
+// servers labeled version=v2.
+func Header(header, label string) Selector {
+	return func(r *http.Request) (map[string]string, bool) {
+		v := r.Header.Get(header)
+		if v == "" {
+			return nil, false
+		}
+		return map[string]string{label: v}, true
+	}
+}
+
+// Factory creates the inner load balancer of a subset of servers.
+type Factory func(servers ...*proxy.Proxy) (roundrobin.RoundRobin, error)
+
+// Subset routes each request to the subset of servers matching the labels
+// selected from the request, see proxy.WithLabels, and delegates the
+// selection to an inner load balancer over that subset. The inner load
+// balancers are cached per label selector and kept up to date as servers
+// are added or removed. Requests selecting no subset, or an empty one,
+// use the fallback subset. Empty subsets are not cached, so the cache is
+// bounded by the label values of the servers whatever the requests select.
+type Subset struct {
+	sync.RWMutex
+	selector Selector
+	factory  Factory
+	fallback map[string]string
+	servers  []*proxy.Proxy
+	subsets  map[string]*subset
+}
+
+// subset is a cached inner load balancer.
+type subset struct {
+	selector map[string]string
+	balancer roundrobin.RoundRobin
+}
+
+// New creates a subset load balancer with the selector and the servers.
+func New(selector Selector, servers []*proxy.Proxy, opts ...Opts) (*Subset, error) {
+	if selector == nil {
+		return nil, ErrSelectorEmpty
+	}
+
+	s := &Subset{
+		selector: selector,
+		factory:  roundrobin.New,
+		servers:  make([]*proxy.Proxy, len(servers)),
+		subsets:  make(map[string]*subset),
+	}
+	copy(s.servers, servers)
+	for _, server := range servers {
+		server.Start()
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// NextServer returns the next server of the subset selected by the request,
+// or of the fallback subset. It returns nil if both subsets are empty.
+func (s *Subset) NextServer(r *http.Request) *proxy.Proxy {
+	if selector, ok := s.selector(r); ok {
+		if server := s.next(selector); server != nil {
+			return server
+		}
+	}
+	return s.next(s.fallback)
+}
+
+// ServeHTTP forwards the request to the server selected by NextServer.
+// It responds with 503 Service Unavailable if no server is available.
+func (s *Subset) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := s.NextServer(r)
+	if server == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	server.ServeHTTP(w, r)
+}
+
+// AddServers adds the servers, starts their health checks and updates the cached subsets they match.
+func (s *Subset) AddServers(servers ...*proxy.Proxy) error {
+	if len(servers) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, server := range servers {
+		server.Start()
+	}
+	s.servers = append(s.servers, servers...)
+	for _, sub := range s.subsets {
+		matched := proxy.Filter(servers, sub.selector)
+		if len(matched) == 0 {
+			continue
+		}
+		if err := sub.balancer.AddServers(matched...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveServers removes the servers, updates the cached subsets and stops
+// the health checks of the removed servers.
+func (s *Subset) RemoveServers(names ...string) error {
+	if len(names) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	remove := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		remove[name] = struct{}{}
+	}
+
+	s.Lock()
+	var kept, removed []*proxy.Proxy
+	for _, server := range s.servers {
+		if _, ok := remove[server.GetName()]; ok {
+			removed = append(removed, server)
+		} else {
+			kept = append(kept, server)
+		}
+	}
+	s.servers = kept
+
+	// The inner load balancers are updated under the lock, so that a concurrent
+	// AddServers of the same servers is applied after the removal. They also stop
+	// the health checks of the removed servers, and release what they hold for
+	// them such as health subscriptions.
+	for key, sub := range s.subsets {
+		_ = sub.balancer.RemoveServers(names...)
+		if len(proxy.Filter(kept, sub.selector)) == 0 {
+			delete(s.subsets, key)
+		}
+	}
+	for _, server := range removed {
+		_ = server.Close()
+	}
+	s.Unlock()
+	return nil
+}
+
+// Servers returns a list of all servers.
+func (s *Subset) Servers() []*proxy.Proxy {
+	s.RLock()
+	defer s.RUnlock()
+
+	servers := make([]*proxy.Proxy, len(s.servers))
+	copy(servers, s.servers)
+	return servers
+}
+
+// RemoveAll removes all servers and stops their health checks.
+func (s *Subset) RemoveAll() {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, sub := range s.subsets {
+		sub.balancer.RemoveAll()
+	}
+	for _, server := range s.servers {
+		_ = server.Close()
+	}
+	s.servers = nil
+	s.subsets = make(map[string]*subset)
+}
+
+// next returns the next server of the subset matching the selector,
+// or nil if no server matches.
+func (s *Subset) next(selector map[string]string) *proxy.Proxy {
+	key := selectorKey(selector)
+
+	s.RLock()
+	sub, ok := s.subsets[key]
+	s.RUnlock()
+
+	if !ok {
+		s.Lock()
+		if sub, ok = s.subsets[key]; !ok {
+			matched := proxy.Filter(s.servers, selector)
+			if len(matched) == 0 {
+				s.Unlock()
+				return nil
+			}
+			balancer, err := s.factory(matched...)
+			if err != nil {
+				s.Unlock()
+				return nil
+			}
+			sub = &subset{selector: selector, balancer: balancer}
+			s.subsets[key] = sub
+		}
+		s.Unlock()
+	}
+
+	return sub.balancer.NextServer()
+}
+
+// selectorKey returns a canonical representation of the selector.
+// The keys and values come from the requests, so they are quoted to keep
+// the separators unambiguous.
+func selectorKey(selector map[string]string) string {
+	pairs := make([]string, 0, len(selector))
+	for k, v := range selector {
+		pairs = append(pairs, strconv.Quote(k)+"="+strconv.Quote(v))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
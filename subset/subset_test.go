@@ -0,0 +1,177 @@
+package subset
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+func newProxy(name, version string) *proxy.Proxy {
+	return proxy.NewProxy(name, &url.URL{Host: name}, proxy.WithLabels(map[string]string{"version": version}))
+}
+
+func newRequest(version string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	if version != "" {
+		req.Header.Set("X-Version", version)
+	}
+	return req
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(nil, nil); !errors.Is(err, ErrSelectorEmpty) {
+		t.Fatalf("New() error = %v, want %v", err, ErrSelectorEmpty)
+	}
+}
+
+func TestSubset_NextServer(t *testing.T) {
+	s, err := New(
+		Header("X-Version", "version"),
+		[]*proxy.Proxy{
+			newProxy("a1", "v1"),
+			newProxy("a2", "v1"),
+			newProxy("b1", "v2"),
+		},
+		WithFallback(map[string]string{"version": "v1"}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create subset: %v", err)
+	}
+	defer s.RemoveAll()
+
+	tests := []struct {
+		name    string
+		version string
+		want    map[string]bool
+	}{
+		{name: "v2 subset", version: "v2", want: map[string]bool{"b1": true}},
+		{name: "v1 subset", version: "v1", want: map[string]bool{"a1": true, "a2": true}},
+		{name: "no selector uses fallback", version: "", want: map[string]bool{"a1": true, "a2": true}},
+		{name: "empty subset uses fallback", version: "v3", want: map[string]bool{"a1": true, "a2": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 4; i++ {
+				name := s.NextServer(newRequest(tt.version)).GetName()
+				if !tt.want[name] {
+					t.Fatalf("Unexpected server %s for version %q", name, tt.version)
+				}
+			}
+		})
+	}
+
+	// The cached subsets are updated when servers are added and removed.
+	if err := s.AddServers(newProxy("b2", "v2")); err != nil {
+		t.Fatalf("Failed to add servers: %v", err)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		seen[s.NextServer(newRequest("v2")).GetName()] = true
+	}
+	if !seen["b1"] || !seen["b2"] {
+		t.Fatalf("Expected b1 and b2 in the v2 subset, but got %v", seen)
+	}
+
+	if err := s.RemoveServers("b1", "b2"); err != nil {
+		t.Fatalf("Failed to remove servers: %v", err)
+	}
+	if name := s.NextServer(newRequest("v2")).GetName(); name != "a1" && name != "a2" {
+		t.Fatalf("Expected fallback after removing the v2 subset, but got %s", name)
+	}
+	if got := len(s.Servers()); got != 2 {
+		t.Fatalf("Expected 2 servers, but got %d", got)
+	}
+
+	// Selectors matching no server are not cached.
+	for i := 0; i < 100; i++ {
+		s.NextServer(newRequest(fmt.Sprintf("random-%d", i)))
+	}
+	s.RLock()
+	cached := len(s.subsets)
+	s.RUnlock()
+	if cached != 1 {
+		t.Fatalf("Expected only the v1 subset to be cached, but got %d subsets", cached)
+	}
+}
+
+func TestSelectorKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b map[string]string
+	}{
+		{
+			name: "separator in value",
+			a:    map[string]string{"version": "v1,zone=a"},
+			b:    map[string]string{"version": "v1", "zone": "a"},
+		},
+		{
+			name: "equal sign in key",
+			a:    map[string]string{"version=v1": ""},
+			b:    map[string]string{"version": "v1="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if selectorKey(tt.a) == selectorKey(tt.b) {
+				t.Errorf("selectorKey(%v) = selectorKey(%v) = %s", tt.a, tt.b, selectorKey(tt.a))
+			}
+		})
+	}
+
+	a := map[string]string{"version": "v1", "zone": "a"}
+	b := map[string]string{"zone": "a", "version": "v1"}
+	if selectorKey(a) != selectorKey(b) {
+		t.Errorf("Expected the same key for equal selectors, got %s and %s", selectorKey(a), selectorKey(b))
+	}
+}
+
+func TestSubset_AddRemoveConcurrent(t *testing.T) {
+	b1 := newProxy("b1", "v2")
+	s, _ := New(Header("X-Version", "version"), []*proxy.Proxy{newProxy("b0", "v2"), b1})
+	defer s.RemoveAll()
+	s.NextServer(newRequest("v2"))
+	key := selectorKey(map[string]string{"version": "v2"})
+
+	for i := 0; i < 50; i++ {
+		// The concurrent removal runs before or after the addition.
+		_ = s.RemoveServers("b1")
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = s.RemoveServers("b1")
+		}()
+		go func() {
+			defer wg.Done()
+			_ = s.AddServers(b1)
+		}()
+		wg.Wait()
+
+		// The cached subset holds exactly the matching servers of the load balancer.
+		want := len(s.Servers())
+		s.RLock()
+		got := len(s.subsets[key].balancer.Servers())
+		s.RUnlock()
+		if got != want {
+			t.Fatalf("Expected %d servers in the v2 subset, but got %d", want, got)
+		}
+	}
+}
+
+func TestSubset_ServeHTTP(t *testing.T) {
+	s, _ := New(Header("X-Version", "version"), nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, newRequest("v1"))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
@@ -0,0 +1,56 @@
+package tcp
+
+import (
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxyproto"
+)
+
+// Opts configures a Server.
+type Opts func(*Server)
+
+// WithDialTimeout sets the maximum time to connect to a backend.
+// Defaults to 5 seconds; zero means no timeout.
+func WithDialTimeout(d time.Duration) Opts {
+	return func(s *Server) {
+		s.dialTimeout = d
+	}
+}
+
+// WithIdleTimeout closes the connections when no data is sent either way
+// for the duration. By default the connections are kept open.
+func WithIdleTimeout(d time.Duration) Opts {
+	return func(s *Server) {
+		s.idleTimeout = d
+	}
+}
+
+// WithAcceptProxyProtocol requires a PROXY protocol v1 or v2 header on every
+// accepted connection, sent by a load balancer in front of the server, and
+// uses the client address it carries. The connections without a valid header
+// within the timeout are closed; a zero timeout waits forever. Only enable it
+// when every client is a trusted proxy, since the header can be forged.
+func WithAcceptProxyProtocol(timeout time.Duration) Opts {
+	return func(s *Server) {
+		s.acceptProxy = true
+		s.proxyHeaderTimeout = timeout
+	}
+}
+
+// WithSendProxyProtocol sends a PROXY protocol header of the version to the
+// backends with the given names, or to all backends when no name is given,
+// so that they receive the client address.
+func WithSendProxyProtocol(version proxyproto.Version, names ...string) Opts {
+	return func(s *Server) {
+		if len(names) == 0 {
+			s.sendProxyAll = version
+			return
+		}
+		if s.sendProxy == nil {
+			s.sendProxy = make(map[string]proxyproto.Version)
+		}
+		for _, name := range names {
+			s.sendProxy[name] = version
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package tcp
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxyproto"
+)
+
+var (
+	// ErrServerClosed is returned by Serve after the server is closed.
+	ErrServerClosed = errors.New("tcp: server closed")
+	// ErrInvalidTimeout is returned when a timeout is negative.
+	ErrInvalidTimeout = errors.New("tcp: timeouts must not be negative")
+)
+
+const (
+	// Default maximum time to connect to a backend
+	defaultDialTimeout = 5 * time.Second
+)
+
+// Server is a layer 4 load balancer. It accepts TCP connections and
+// forwards the bytes in both directions to a backend selected by the
+// balancer, such as roundrobin.RoundRobin. The backend address is the
+// host of the proxy address, for example tcp://10.0.0.1:5432.
+//
+// The health checks of the proxies are HTTP checks by default;
+// use health.TCPCheck for backends that do not speak HTTP.
+//
+// The server can accept PROXY protocol headers from a load balancer in front
+// of it, and send them to the backends so that they know the client address.
+type Server struct {
+	balancer    proxy.Balancer
+	dialTimeout time.Duration
+	idleTimeout time.Duration
+
+	acceptProxy        bool
+	proxyHeaderTimeout time.Duration
+	sendProxy          map[string]proxyproto.Version
+	sendProxyAll       proxyproto.Version
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+// New creates a layer 4 load balancer forwarding the connections to the servers of the balancer.
+func New(balancer proxy.Balancer, opts ...Opts) (*Server, error) {
+	s := &Server{
+		balancer:    balancer,
+		dialTimeout: defaultDialTimeout,
+		listeners:   make(map[net.Listener]struct{}),
+		conns:       make(map[net.Conn]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.dialTimeout < 0 || s.idleTimeout < 0 || s.proxyHeaderTimeout < 0 {
+		return nil, ErrInvalidTimeout
+	}
+
+	for _, v := range s.sendProxy {
+		if v != proxyproto.V1 && v != proxyproto.V2 {
+			return nil, proxyproto.ErrInvalidVersion
+		}
+	}
+	if s.sendProxyAll != 0 && s.sendProxyAll != proxyproto.V1 && s.sendProxyAll != proxyproto.V2 {
+		return nil, proxyproto.ErrInvalidVersion
+	}
+
+	return s, nil
+}
+
+// ListenAndServe listens on the TCP network address and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts the connections of the listener and forwards each of them to
+// a backend. It blocks until the listener fails or the server is closed, in
+// which case it returns ErrServerClosed. The listener is closed on return.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.trackListener(l, true) {
+		_ = l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		// The handler is registered under the lock so that Close waits for it.
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			_ = conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer s.trackConn(conn, false)
+			s.handle(conn)
+		}()
+	}
+}
+
+// Close stops the listeners, closes the open connections and waits
+// for their handlers to return.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		_ = l.Close()
+	}
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// handle forwards the client connection to a backend.
+// If a backend cannot be reached, the next one is tried, up to one
+// attempt per server of the balancer.
+func (s *Server) handle(client net.Conn) {
+	defer client.Close()
+
+	if s.acceptProxy {
+		conn, err := proxyproto.Accept(client, s.proxyHeaderTimeout)
+		if err != nil {
+			return
+		}
+		client = conn
+	}
+
+	attempts := len(s.balancer.Servers())
+	for i := 0; i < attempts; i++ {
+		backend := s.balancer.NextServer()
+		if backend == nil {
+			return
+		}
+
+		conn, err := net.DialTimeout("tcp", backend.Addr().Host, s.dialTimeout)
+		if err != nil {
+			continue
+		}
+
+		if err := s.writeProxyHeader(conn, client, backend); err != nil {
+			_ = conn.Close()
+			continue
+		}
+
+		if !s.trackConn(conn, true) {
+			_ = conn.Close()
+			return
+		}
+
+		// The connection counts in the loading of the backend, like a request.
+		backend.Acquire()
+		s.pipe(client, conn)
+		backend.Release()
+
+		s.trackConn(conn, false)
+		_ = conn.Close()
+		return
+	}
+}
+
+// pipe copies the bytes in both directions until both sides are done.
+// When one side stops sending, the write side of the other connection is
+// closed so that half-closed connections keep working.
+func (s *Server) pipe(client, backend net.Conn) {
+	// Without idle timeout, the raw connections are copied so that
+	// io.Copy can use splice on Linux.
+	left, right := client, backend
+	if s.idleTimeout > 0 {
+		idle := &idleTimer{conns: [2]net.Conn{client, backend}, timeout: s.idleTimeout}
+		idle.extend()
+		left, right = &idleConn{client, idle}, &idleConn{backend, idle}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	copyConn := func(dst, src net.Conn) {
+		defer wg.Done()
+		if _, err := io.Copy(dst, src); err != nil {
+			// Unblock the other direction.
+			_ = client.Close()
+			_ = backend.Close()
+			return
+		}
+		closeWrite(dst)
+	}
+	go copyConn(right, left)
+	go copyConn(left, right)
+	wg.Wait()
+}
+
+// writeProxyHeader sends the PROXY protocol header with the addresses
+// of the client connection, if enabled for the backend.
+func (s *Server) writeProxyHeader(conn, client net.Conn, backend *proxy.Proxy) error {
+	version, ok := s.sendProxy[backend.GetName()]
+	if !ok {
+		version = s.sendProxyAll
+	}
+	if version == 0 {
+		return nil
+	}
+
+	h := &proxyproto.Header{
+		Version:     version,
+		Command:     proxyproto.CommandProxy,
+		Source:      client.RemoteAddr(),
+		Destination: client.LocalAddr(),
+	}
+	_, err := h.WriteTo(conn)
+	return err
+}
+
+// trackListener registers or unregisters the listener.
+// It returns false if the server is closed.
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.listeners, l)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	s.listeners[l] = struct{}{}
+	return true
+}
+
+// trackConn registers or unregisters the connection.
+// It returns false if the server is closed.
+func (s *Server) trackConn(c net.Conn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !add {
+		delete(s.conns, c)
+		return true
+	}
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// closeWrite closes the write side of the connection if supported,
+// or the whole connection otherwise.
+func closeWrite(c net.Conn) {
+	if ic, ok := c.(*idleConn); ok {
+		c = ic.Conn
+	}
+	if pc, ok := c.(*proxyproto.Conn); ok {
+		c = pc.NetConn()
+	}
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+		return
+	}
+	_ = c.Close()
+}
+
+// idleTimer pushes back the deadline of both connections of a pipe,
+// so that they are closed only when no data flows either way.
+type idleTimer struct {
+	conns   [2]net.Conn
+	timeout time.Duration
+}
+
+func (t *idleTimer) extend() {
+	deadline := time.Now().Add(t.timeout)
+	for _, c := range t.conns {
+		_ = c.SetDeadline(deadline)
+	}
+}
+
+// idleConn is a connection extending the idle timer on every read and write.
+type idleConn struct {
+	net.Conn
+	timer *idleTimer
+}
+
+func (c *idleConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.timer.extend()
+	}
+	return n, err
+}
+
+func (c *idleConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.timer.extend()
+	}
+	return n, err
+}
@@ -0,0 +1,280 @@
+package tcp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/proxyproto"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// newBackend starts a TCP server replying with its name followed by
+// everything it received, once the client closed its write side.
+func newBackend(t *testing.T, name string) *proxy.Proxy {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				_, _ = conn.Write(append([]byte(name+":"), data...))
+			}()
+		}
+	}()
+
+	return newProxy(t, name, "tcp://"+l.Addr().String())
+}
+
+func newProxy(t *testing.T, name, addr string) *proxy.Proxy {
+	t.Helper()
+	u, _ := url.Parse(addr)
+	h, _ := health.New(u, health.WithCheck(health.TCPCheck), health.WithInitialDelay(time.Hour))
+	p := proxy.NewProxy(name, u, proxy.WithHealth(h))
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+// serve starts the server on a loopback listener and returns its address.
+func serve(t *testing.T, s *Server) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return l.Addr().String()
+}
+
+// send writes the message, half-closes the connection and returns the reply.
+func send(t *testing.T, addr, msg string) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	_, _ = io.WriteString(conn, msg)
+	_ = conn.(*net.TCPConn).CloseWrite()
+	reply, _ := io.ReadAll(conn)
+	return string(reply)
+}
+
+func TestNew(t *testing.T) {
+	pool, _ := roundrobin.New()
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "default"},
+		{name: "invalid dial timeout", opts: []Opts{WithDialTimeout(-1)}, err: ErrInvalidTimeout},
+		{name: "invalid idle timeout", opts: []Opts{WithIdleTimeout(-1)}, err: ErrInvalidTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(pool, tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestServer_Forward(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"), newBackend(t, "s2"))
+	s, _ := New(pool)
+	addr := serve(t, s)
+
+	got := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		got[send(t, addr, "hello")] = true
+	}
+
+	if len(got) != 2 || !got["s1:hello"] || !got["s2:hello"] {
+		t.Errorf("Replies = %v, want s1:hello and s2:hello", got)
+	}
+}
+
+func TestServer_DialFailure(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	down := newProxy(t, "down", "tcp://"+l.Addr().String())
+	_ = l.Close()
+
+	pool, _ := roundrobin.New(down, newBackend(t, "s1"))
+	s, _ := New(pool, WithDialTimeout(time.Second))
+	addr := serve(t, s)
+
+	for i := 0; i < 2; i++ {
+		if got := send(t, addr, "hello"); got != "s1:hello" {
+			t.Errorf("Reply = %q, want %q", got, "s1:hello")
+		}
+	}
+}
+
+func TestServer_LoadingAndIdle(t *testing.T) {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// Keep the connection open until the peer closes it.
+			go func() { _, _ = io.Copy(io.Discard, conn) }()
+		}
+	}()
+	backend := newProxy(t, "s1", "tcp://"+l.Addr().String())
+
+	pool, _ := roundrobin.New(backend)
+	s, _ := New(pool, WithIdleTimeout(100*time.Millisecond))
+	addr := serve(t, s)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer conn.Close()
+
+	waitLoading(t, backend, 1)
+
+	// The open connection holds the drain of the backend.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := backend.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The connection is closed by the balancer once idle.
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("Read() error = %v, want EOF", err)
+	}
+	waitLoading(t, backend, 0)
+	if err := backend.Drain(context.Background()); err != nil {
+		t.Errorf("Drain() error = %v, want nil", err)
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"))
+	s, _ := New(pool)
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(l) }()
+
+	if got := send(t, l.Addr().String(), "hello"); got != "s1:hello" {
+		t.Errorf("Reply = %q, want %q", got, "s1:hello")
+	}
+
+	_ = s.Close()
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() error = %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func waitLoading(t *testing.T, backend *proxy.Proxy, want uint32) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for backend.GetLoading() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("GetLoading() = %d, want %d", backend.GetLoading(), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestServer_ProxyProtocol(t *testing.T) {
+	// The backend replies with the client address of the PROXY protocol header.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				pc, err := proxyproto.Accept(conn, time.Second)
+				if err != nil {
+					_, _ = io.WriteString(conn, "no header")
+					return
+				}
+				_, _ = io.WriteString(conn, pc.RemoteAddr().String())
+			}()
+		}
+	}()
+	backend := newProxy(t, "s1", "tcp://"+l.Addr().String())
+
+	tests := []struct {
+		name   string
+		opts   []Opts
+		header string
+		want   string
+	}{
+		{
+			name: "send",
+			opts: []Opts{WithSendProxyProtocol(proxyproto.V2, "s1")},
+			want: "127.0.0.1:",
+		},
+		{
+			name:   "accept and send",
+			opts:   []Opts{WithAcceptProxyProtocol(time.Second), WithSendProxyProtocol(proxyproto.V1)},
+			header: "PROXY TCP4 203.0.113.7 192.0.2.2 1234 443\r\n",
+			want:   "203.0.113.7:1234",
+		},
+		{
+			name: "other backend",
+			opts: []Opts{WithSendProxyProtocol(proxyproto.V1, "s2")},
+			want: "no header",
+		},
+		{
+			name: "missing header",
+			opts: []Opts{WithAcceptProxyProtocol(time.Second), WithSendProxyProtocol(proxyproto.V1)},
+			want: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, _ := roundrobin.New(backend)
+			s, err := New(pool, tt.opts...)
+			if err != nil {
+				t.Fatalf("Failed to create server: %v", err)
+			}
+			got := send(t, serve(t, s), tt.header)
+
+			if !strings.HasPrefix(got, tt.want) || (tt.want == "" && got != "") {
+				t.Errorf("Reply = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+
+	pool, _ := roundrobin.New(backend)
+	if _, err := New(pool, WithSendProxyProtocol(3)); !errors.Is(err, proxyproto.ErrInvalidVersion) {
+		t.Errorf("New() error = %v, want %v", err, proxyproto.ErrInvalidVersion)
+	}
+}
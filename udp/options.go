@@ -0,0 +1,38 @@
+package udp
+
+import "time"
+
+// Opts configures a Server.
+type Opts func(*Server)
+
+// WithSessionTimeout sets the time after which a session without any
+// datagram either way is removed. Defaults to 30 seconds.
+func WithSessionTimeout(d time.Duration) Opts {
+	return func(s *Server) {
+		s.sessionTimeout = d
+	}
+}
+
+// WithBufferSize sets the size of the buffers receiving the datagrams;
+// larger datagrams are truncated. Defaults to 65535 bytes.
+func WithBufferSize(size int) Opts {
+	return func(s *Server) {
+		s.bufferSize = size
+	}
+}
+
+// WithMaxSessions sets the maximum number of sessions. The datagrams of new
+// clients are dropped while the limit is reached. Defaults to 4096.
+func WithMaxSessions(n int) Opts {
+	return func(s *Server) {
+		s.maxSessions = n
+	}
+}
+
+// WithPerPacket selects a backend for every datagram instead of once per session,
+// for stateless protocols. The responses still flow back to the client.
+func WithPerPacket() Opts {
+	return func(s *Server) {
+		s.perPacket = true
+	}
+}
@@ -0,0 +1,65 @@
+package udp
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// resolveInterval is the interval at which the backend addresses are resolved again.
+const resolveInterval = 30 * time.Second
+
+// resolver caches the UDP addresses of the backends, so that the read loop
+// never waits for a DNS lookup. The host names are resolved in the background
+// and the datagrams for a backend not resolved yet are dropped; the cached
+// address is used while it is resolved again.
+type resolver struct {
+	mu    sync.Mutex
+	addrs map[string]*resolved
+}
+
+// resolved is a cached backend address.
+type resolved struct {
+	addr    *net.UDPAddr
+	at      time.Time
+	pending bool
+}
+
+// resolve returns the address of the backend host, or nil if it is not resolved yet.
+func (r *resolver) resolve(host string) *net.UDPAddr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.addrs == nil {
+		r.addrs = make(map[string]*resolved)
+	}
+	e, ok := r.addrs[host]
+	if !ok {
+		e = &resolved{}
+		r.addrs[host] = e
+		// An IP address does not need a lookup.
+		if h, _, err := net.SplitHostPort(host); err == nil && net.ParseIP(h) != nil {
+			if addr, err := net.ResolveUDPAddr("udp", host); err == nil {
+				e.addr, e.at = addr, time.Now()
+			}
+		}
+	}
+
+	if !e.pending && (e.addr == nil || time.Since(e.at) >= resolveInterval) {
+		e.pending = true
+		go r.lookup(host, e)
+	}
+	return e.addr
+}
+
+// lookup resolves the host and updates the cached address.
+func (r *resolver) lookup(host string, e *resolved) {
+	addr, err := net.ResolveUDPAddr("udp", host)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.pending = false
+	if err == nil {
+		e.addr, e.at = addr, time.Now()
+	}
+}
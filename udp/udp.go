@@ -0,0 +1,310 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+)
+
+var (
+	// ErrServerClosed is returned by Serve after the server is closed.
+	ErrServerClosed = errors.New("udp: server closed")
+	// ErrInvalidSessionTimeout is returned when the session timeout is not positive.
+	ErrInvalidSessionTimeout = errors.New("udp: session timeout must be greater than zero")
+	// ErrInvalidBufferSize is returned when the buffer size is not positive.
+	ErrInvalidBufferSize = errors.New("udp: buffer size must be greater than zero")
+	// ErrInvalidMaxSessions is returned when the session limit is less than 1.
+	ErrInvalidMaxSessions = errors.New("udp: max sessions must be at least 1")
+)
+
+const (
+	// Default time after which an idle session is removed
+	defaultSessionTimeout = 30 * time.Second
+	// Default size of the packet buffers, the maximum UDP payload
+	defaultBufferSize = 65535
+	// Default maximum number of sessions
+	defaultMaxSessions = 4096
+)
+
+// Server is a UDP load balancer. The datagrams of a client are forwarded to
+// a backend selected by the balancer, such as roundrobin.RoundRobin, through
+// a session: a socket connected to the backend, so that the responses flow
+// back to the right client. The sessions are removed after being idle for
+// the session timeout. The backend address is the host of the proxy
+// address, for example udp://10.0.0.1:53.
+//
+// The health checks of proxies with a udp:// address are disabled by
+// default, so that the backends are healthy after the first check; use
+// health.WithCheck to probe the backends with the protocol they serve.
+//
+// Host names are resolved in the background: the datagrams for a backend
+// whose host name is not resolved yet are dropped, as on a lossy network,
+// and the client is expected to retransmit them.
+//
+// Each session holds a socket, so the number of sessions is limited: the
+// datagrams of new clients are dropped while the session table is full.
+//
+// By default a client keeps its backend for the lifetime of its session.
+// In per-packet mode, a backend is selected for every datagram, which suits
+// stateless protocols such as DNS or syslog.
+type Server struct {
+	balancer       proxy.Balancer
+	sessionTimeout time.Duration
+	bufferSize     int
+	maxSessions    int
+	perPacket      bool
+	resolver       resolver
+
+	mu       sync.Mutex
+	conns    map[net.PacketConn]struct{}
+	sessions map[sessionKey]*session
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// sessionKey identifies a session. The backend is only set in per-packet mode.
+type sessionKey struct {
+	client  string
+	backend *proxy.Proxy
+}
+
+// session forwards the datagrams of a client to a backend.
+type session struct {
+	key      sessionKey
+	client   net.Addr
+	frontend net.PacketConn
+	upstream net.Conn
+	backend  *proxy.Proxy
+	// lastSeen is the time of the last datagram, in Unix nanoseconds.
+	lastSeen int64
+}
+
+// New creates a UDP load balancer forwarding the datagrams to the servers of the balancer.
+func New(balancer proxy.Balancer, opts ...Opts) (*Server, error) {
+	s := &Server{
+		balancer:       balancer,
+		sessionTimeout: defaultSessionTimeout,
+		bufferSize:     defaultBufferSize,
+		maxSessions:    defaultMaxSessions,
+		conns:          make(map[net.PacketConn]struct{}),
+		sessions:       make(map[sessionKey]*session),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	switch {
+	case s.sessionTimeout <= 0:
+		return nil, ErrInvalidSessionTimeout
+	case s.bufferSize <= 0:
+		return nil, ErrInvalidBufferSize
+	case s.maxSessions < 1:
+		return nil, ErrInvalidMaxSessions
+	}
+
+	return s, nil
+}
+
+// ListenAndServe listens on the UDP network address and calls Serve.
+func (s *Server) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(conn)
+}
+
+// Serve reads the datagrams of the connection and forwards them to the
+// backends. It blocks until reading fails or the server is closed, in
+// which case it returns ErrServerClosed. The connection is closed on return.
+func (s *Server) Serve(conn net.PacketConn) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = conn.Close()
+		return ErrServerClosed
+	}
+	s.conns[conn] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+
+	buf := make([]byte, s.bufferSize)
+	for {
+		n, client, err := conn.ReadFrom(buf)
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		sess := s.session(conn, client)
+		if sess == nil {
+			continue
+		}
+		sess.touch()
+		_, _ = sess.upstream.Write(buf[:n])
+	}
+}
+
+// Close stops the servers, removes the sessions and waits for them to complete.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	for _, sess := range s.sessions {
+		_ = sess.upstream.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
+
+// Sessions returns the number of open sessions.
+func (s *Server) Sessions() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// session returns the session of the client, creating it if needed,
+// or nil if no backend is available or the session table is full.
+func (s *Server) session(frontend net.PacketConn, client net.Addr) *session {
+	key := sessionKey{client: client.String()}
+	if s.perPacket {
+		key.backend = s.balancer.NextServer()
+		if key.backend == nil {
+			return nil
+		}
+	}
+
+	s.mu.Lock()
+	sess, ok := s.sessions[key]
+	full := len(s.sessions) >= s.maxSessions
+	s.mu.Unlock()
+	if ok {
+		return sess
+	}
+	if full {
+		return nil
+	}
+
+	backend := key.backend
+	if backend == nil {
+		backend = s.balancer.NextServer()
+		if backend == nil {
+			return nil
+		}
+	}
+
+	raddr := s.resolver.resolve(backend.Addr().Host)
+	if raddr == nil {
+		return nil
+	}
+	upstream, err := net.DialUDP("udp", nil, raddr)
+	if err != nil {
+		return nil
+	}
+
+	sess = &session{
+		key:      key,
+		client:   client,
+		frontend: frontend,
+		upstream: upstream,
+		backend:  backend,
+		lastSeen: time.Now().UnixNano(),
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = upstream.Close()
+		return nil
+	}
+	// Another frontend created the session in the meantime.
+	if existing, ok := s.sessions[key]; ok {
+		s.mu.Unlock()
+		_ = upstream.Close()
+		return existing
+	}
+	if len(s.sessions) >= s.maxSessions {
+		s.mu.Unlock()
+		_ = upstream.Close()
+		return nil
+	}
+	s.sessions[key] = sess
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go s.reply(sess)
+	return sess
+}
+
+// reply forwards the responses of the backend to the client
+// until the session is idle for the session timeout.
+func (s *Server) reply(sess *session) {
+	defer s.wg.Done()
+	defer s.remove(sess)
+
+	buf := make([]byte, s.bufferSize)
+	for {
+		_ = sess.upstream.SetReadDeadline(sess.expiry(s.sessionTimeout))
+		n, err := sess.upstream.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() && time.Now().Before(sess.expiry(s.sessionTimeout)) {
+				// A datagram was received from the client since the deadline was set.
+				continue
+			}
+			return
+		}
+
+		sess.touch()
+		_, _ = sess.frontend.WriteTo(buf[:n], sess.client)
+	}
+}
+
+// remove closes the session and removes it from the session table.
+func (s *Server) remove(sess *session) {
+	s.mu.Lock()
+	if s.sessions[sess.key] == sess {
+		delete(s.sessions, sess.key)
+	}
+	s.mu.Unlock()
+
+	_ = sess.upstream.Close()
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// touch records the activity of the session.
+func (sess *session) touch() {
+	atomic.StoreInt64(&sess.lastSeen, time.Now().UnixNano())
+}
+
+// expiry returns the time at which the session expires if it stays idle.
+func (sess *session) expiry(timeout time.Duration) time.Time {
+	return time.Unix(0, atomic.LoadInt64(&sess.lastSeen)).Add(timeout)
+}
@@ -0,0 +1,255 @@
+package udp
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// newBackend starts a UDP server replying to every datagram with its name
+// followed by the datagram.
+func newBackend(t *testing.T, name string) *proxy.Proxy {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = conn.WriteTo(append([]byte(name+":"), buf[:n]...), addr)
+		}
+	}()
+
+	addr, _ := url.Parse("udp://" + conn.LocalAddr().String())
+	return proxytest.NewProxyWithAddr(t, name, addr, health.StateHealthy)
+}
+
+// serve starts the server on a loopback socket and returns its address.
+func serve(t *testing.T, s *Server) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go func() { _ = s.Serve(conn) }()
+	t.Cleanup(func() { _ = s.Close() })
+	return conn.LocalAddr().String()
+}
+
+// dial returns a client socket connected to the server.
+func dial(t *testing.T, addr string) net.Conn {
+	t.Helper()
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// exchange sends the message and returns the reply.
+func exchange(t *testing.T, conn net.Conn, msg string) string {
+	t.Helper()
+	_, _ = conn.Write([]byte(msg))
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 1024)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read the reply: %v", err)
+	}
+	return string(buf[:n])
+}
+
+func TestNew(t *testing.T) {
+	pool, _ := roundrobin.New()
+
+	tests := []struct {
+		name string
+		opts []Opts
+		err  error
+	}{
+		{name: "default"},
+		{name: "invalid session timeout", opts: []Opts{WithSessionTimeout(0)}, err: ErrInvalidSessionTimeout},
+		{name: "invalid buffer size", opts: []Opts{WithBufferSize(0)}, err: ErrInvalidBufferSize},
+		{name: "invalid max sessions", opts: []Opts{WithMaxSessions(0)}, err: ErrInvalidMaxSessions},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(pool, tt.opts...); !errors.Is(err, tt.err) {
+				t.Errorf("New() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestServer_Sessions(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"), newBackend(t, "s2"))
+	s, _ := New(pool)
+	addr := serve(t, s)
+
+	c1, c2 := dial(t, addr), dial(t, addr)
+	first := exchange(t, c1, "a")
+	second := exchange(t, c2, "b")
+	if first[:2] == second[:2] {
+		t.Errorf("Expected the clients on different backends, got %q and %q", first, second)
+	}
+
+	// A client keeps its backend for the lifetime of its session.
+	for i := 0; i < 3; i++ {
+		if got := exchange(t, c1, "a"); got != first {
+			t.Errorf("Reply = %q, want %q", got, first)
+		}
+	}
+
+	if got := s.Sessions(); got != 2 {
+		t.Errorf("Sessions() = %d, want 2", got)
+	}
+}
+
+func TestServer_MaxSessions(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"))
+	s, _ := New(pool, WithMaxSessions(1))
+	addr := serve(t, s)
+
+	c1, c2 := dial(t, addr), dial(t, addr)
+	if got := exchange(t, c1, "a"); got != "s1:a" {
+		t.Errorf("Reply = %q, want %q", got, "s1:a")
+	}
+
+	// The datagram of the second client is dropped while the table is full.
+	_, _ = c2.Write([]byte("b"))
+	_ = c2.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := c2.Read(make([]byte, 1024)); err == nil {
+		t.Errorf("Expected no reply, got %d bytes", n)
+	}
+
+	if got := s.Sessions(); got != 1 {
+		t.Errorf("Sessions() = %d, want 1", got)
+	}
+}
+
+func TestServer_ResolveHost(t *testing.T) {
+	backend := newBackend(t, "s1")
+	_, port, _ := net.SplitHostPort(backend.Addr().Host)
+	named, _ := url.Parse("udp://localhost:" + port)
+
+	pool, _ := roundrobin.New(proxytest.NewProxyWithAddr(t, "s1", named, health.StateHealthy))
+	s, _ := New(pool)
+	addr := serve(t, s)
+
+	// The datagrams are dropped until the host is resolved in the background.
+	conn := dial(t, addr)
+	buf := make([]byte, 1024)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		_, _ = conn.Write([]byte("a"))
+		_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err == nil {
+			if got := string(buf[:n]); got != "s1:a" {
+				t.Errorf("Reply = %q, want %q", got, "s1:a")
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No reply once the host is resolved: %v", err)
+		}
+	}
+}
+
+func TestServer_DefaultHealthCheck(t *testing.T) {
+	backend := newBackend(t, "s1")
+
+	// The default health check of a udp:// proxy is not an HTTP check.
+	server := proxy.NewProxy("s1", backend.Addr())
+	pool, _ := roundrobin.NewWithOptions([]*proxy.Proxy{server}, roundrobin.WithHealthyOnly(false))
+	defer pool.RemoveAll()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.State() != health.StateHealthy {
+		if time.Now().After(deadline) {
+			t.Fatalf("State() = %v, want %v", server.State(), health.StateHealthy)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s, _ := New(pool)
+	conn := dial(t, serve(t, s))
+	if got := exchange(t, conn, "a"); got != "s1:a" {
+		t.Errorf("Reply = %q, want %q", got, "s1:a")
+	}
+}
+
+func TestServer_PerPacket(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"), newBackend(t, "s2"))
+	s, _ := New(pool, WithPerPacket())
+	addr := serve(t, s)
+
+	conn := dial(t, addr)
+	got := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		got[exchange(t, conn, "a")] = true
+	}
+
+	if len(got) != 2 || !got["s1:a"] || !got["s2:a"] {
+		t.Errorf("Replies = %v, want s1:a and s2:a", got)
+	}
+}
+
+func TestServer_SessionTimeout(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"))
+	s, _ := New(pool, WithSessionTimeout(50*time.Millisecond))
+	addr := serve(t, s)
+
+	conn := dial(t, addr)
+	if got := exchange(t, conn, "a"); got != "s1:a" {
+		t.Errorf("Reply = %q, want %q", got, "s1:a")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for s.Sessions() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Sessions() = %d, want 0 after the session timeout", s.Sessions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A new session is created for the next datagram.
+	if got := exchange(t, conn, "b"); got != "s1:b" {
+		t.Errorf("Reply = %q, want %q", got, "s1:b")
+	}
+}
+
+func TestServer_Close(t *testing.T) {
+	pool, _ := roundrobin.New(newBackend(t, "s1"))
+	s, _ := New(pool)
+	conn, _ := net.ListenPacket("udp", "127.0.0.1:0")
+
+	errc := make(chan error, 1)
+	go func() { errc <- s.Serve(conn) }()
+
+	exchange(t, dial(t, conn.LocalAddr().String()), "a")
+
+	_ = s.Close()
+	if err := <-errc; !errors.Is(err, ErrServerClosed) {
+		t.Errorf("Serve() error = %v, want %v", err, ErrServerClosed)
+	}
+	if got := s.Sessions(); got != 0 {
+		t.Errorf("Sessions() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,16 @@
+package weighted
+
+import "github.com/appleboy/loadbalancer-algorithms/slowstart"
+
+// Opts configures a weighted round-robin load balancer.
+type Opts func(*roundrobin)
+
+// WithSlowStart ramps up the weight of every server added with AddServer
+// from a fraction of their weight to the full weight over the slow start window.
+// Every server warms up when it is added, including the first servers of the
+// load balancer, as with roundrobin.WithSlowStart.
+func WithSlowStart(s *slowstart.SlowStart) Opts {
+	return func(r *roundrobin) {
+		r.slowStart = s
+	}
+}
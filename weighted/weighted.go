@@ -2,8 +2,12 @@ package weighted
 
 import (
 	"errors"
+	"math"
 	"net/url"
 	"sync"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/slowstart"
 )
 
 var (
@@ -14,6 +18,8 @@ var (
 type server struct {
 	url    *url.URL
 	weight int
+	// added is the time the server was added, used by the slow start.
+	added time.Time
 }
 
 type RoundRobin interface {
@@ -42,6 +48,13 @@ type roundrobin struct {
 	gcd int
 	// current server list count
 	count int
+
+	// slowStart ramps up the weight of newly added servers.
+	slowStart *slowstart.SlowStart
+	// warming indicates that at least one server may still be in its slow start window.
+	warming bool
+	// weights holds the effective weights of the servers while warming.
+	weights []int
 }
 
 //	while (true) {
@@ -60,6 +73,9 @@ type roundrobin struct {
 //
 // reference: http://kb.linuxvirtualserver.org/wiki/Weighted_Round-Robin_Scheduling
 func (r *roundrobin) NextServer() *url.URL {
+	r.Lock()
+	defer r.Unlock()
+
 	if r.count == 0 {
 		return nil
 	}
@@ -68,6 +84,10 @@ func (r *roundrobin) NextServer() *url.URL {
 		return r.servers[0].url
 	}
 
+	if r.warming {
+		r.refreshWeights(time.Now())
+	}
+
 	for {
 		r.index = (r.index + 1) % r.count
 		if r.index == 0 {
@@ -80,13 +100,56 @@ func (r *roundrobin) NextServer() *url.URL {
 			}
 		}
 
-		if r.servers[r.index].weight >= r.cw {
+		if r.weightOf(r.index) >= r.cw {
 			return r.servers[r.index].url
 		}
 	}
 }
 
+// weightOf returns the effective weight of the server at index i.
+// The caller must hold the lock.
+func (r *roundrobin) weightOf(i int) int {
+	if r.warming {
+		return r.weights[i]
+	}
+	return r.servers[i].weight
+}
+
+// refreshWeights recomputes the effective weights of the servers in their
+// slow start window, together with the greatest common divisor and the
+// maximum weight used by the scheduling. The caller must hold the lock.
+func (r *roundrobin) refreshWeights(now time.Time) {
+	r.weights = r.weights[:0]
+	r.gcd, r.maxWeigt = 0, 0
+	warming := false
+
+	for _, s := range r.servers {
+		weight := s.weight
+		if weight > 0 {
+			factor := r.slowStart.Factor(s.added, now)
+			if factor < 1 {
+				warming = true
+				weight = int(math.Max(1, math.Round(float64(weight)*factor)))
+			}
+			if r.gcd == 0 {
+				r.gcd = weight
+			} else {
+				r.gcd = gcd(r.gcd, weight)
+			}
+			if r.maxWeigt < weight {
+				r.maxWeigt = weight
+			}
+		}
+		r.weights = append(r.weights, weight)
+	}
+
+	r.warming = warming
+}
+
 func (r *roundrobin) AddServer(url *url.URL, weight int) error {
+	r.Lock()
+	defer r.Unlock()
+
 	if weight > 0 {
 		if r.gcd == 0 {
 			r.gcd = weight
@@ -104,8 +167,12 @@ func (r *roundrobin) AddServer(url *url.URL, weight int) error {
 	r.servers = append(r.servers, &server{
 		url:    url,
 		weight: weight,
+		added:  time.Now(),
 	})
 	r.count += 1
+	if r.slowStart != nil {
+		r.warming = true
+	}
 	return nil
 }
 
@@ -134,6 +201,9 @@ func (r *roundrobin) Servers() []*url.URL {
 }
 
 func (r *roundrobin) RemoveAll() {
+	r.Lock()
+	defer r.Unlock()
+
 	r.servers = r.servers[:0]
 	r.count = 0
 	r.cw = 0
@@ -144,11 +214,14 @@ func (r *roundrobin) RemoveAll() {
 
 // Reset resets all current weights.
 func (r *roundrobin) Reset() {
+	r.Lock()
+	defer r.Unlock()
+
 	r.index = -1
 	r.cw = 0
 }
 
-func New() (RoundRobin, error) {
+func New(opts ...Opts) (RoundRobin, error) {
 	rb := &roundrobin{
 		servers: []*server{},
 		count:   0,
@@ -156,6 +229,10 @@ func New() (RoundRobin, error) {
 		cw:      0,
 	}
 
+	for _, opt := range opts {
+		opt(rb)
+	}
+
 	return rb, nil
 }
 
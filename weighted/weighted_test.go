@@ -4,7 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/appleboy/loadbalancer-algorithms/slowstart"
 )
 
 var servers = []*server{
@@ -91,3 +95,62 @@ func BenchmarkNext(b *testing.B) {
 		r.NextServer()
 	}
 }
+
+func TestNextServerSlowStart(t *testing.T) {
+	s, err := slowstart.New(200 * time.Millisecond)
+	if err != nil {
+		t.Fatalf("Failed to create slow start: %v", err)
+	}
+
+	r, _ := New(WithSlowStart(s))
+	_ = r.AddServer(&url.URL{Host: "192.168.1.10"}, 10)
+	time.Sleep(250 * time.Millisecond)
+	_ = r.AddServer(&url.URL{Host: "192.168.1.11"}, 10)
+
+	count := func() int {
+		n := 0
+		for i := 0; i < 200; i++ {
+			if r.NextServer().Host == "192.168.1.11" {
+				n++
+			}
+		}
+		return n
+	}
+
+	// The new server receives only a fraction of its share while warming up.
+	if n := count(); n >= 60 {
+		t.Fatalf("Expected a reduced share while warming up, but got %d/200", n)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	// The new server receives its full share after the slow start window.
+	if n := count(); n != 100 {
+		t.Fatalf("Expected 100/200 after warming up, but got %d", n)
+	}
+}
+
+// The effective weights are refreshed while other goroutines select servers.
+func TestNextServerSlowStartConcurrent(t *testing.T) {
+	s, _ := slowstart.New(50 * time.Millisecond)
+	r, _ := New(WithSlowStart(s))
+	_ = r.AddServer(&url.URL{Host: "192.168.1.10"}, 10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if r.NextServer() == nil {
+					t.Error("Expected a server")
+					return
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 5; i++ {
+		_ = r.AddServer(&url.URL{Host: fmt.Sprintf("192.168.1.%d", 10+i)}, 10*i)
+	}
+	wg.Wait()
+}
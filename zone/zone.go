@@ -0,0 +1,228 @@
+package zone
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/roundrobin"
+)
+
+// Ensure that zone implements the roundrobin.RoundRobin interface.
+var _ roundrobin.RoundRobin = (*zone)(nil)
+
+// zone is a zone aware load balancer. Servers are grouped by the zone of
+// their locality, see proxy.WithLocality, and balanced with round robin
+// inside each zone.
+//
+// Assuming the clients are spread evenly over the zones, the local zone is
+// expected to serve 1/N of the healthy capacity for N zones. As long as it
+// holds at least that share of the healthy servers, all the traffic stays in
+// the local zone. Otherwise the local zone keeps a proportional part of the
+// traffic and the rest spills over to the other zones, weighted by their
+// number of healthy servers.
+type zone struct {
+	sync.RWMutex
+	local string
+	zones map[string]roundrobin.RoundRobin
+	// order holds the zone names in order of first appearance.
+	order []string
+
+	next  uint64
+	spill uint64
+}
+
+// New creates a zone aware load balancer preferring the servers of the local zone.
+func New(local string, servers ...*proxy.Proxy) (roundrobin.RoundRobin, error) {
+	z := &zone{
+		local: local,
+		zones: make(map[string]roundrobin.RoundRobin),
+	}
+
+	if len(servers) > 0 {
+		if err := z.AddServers(servers...); err != nil {
+			return nil, err
+		}
+	}
+
+	return z, nil
+}
+
+// NextServer returns the next server, preferring the local zone.
+// It returns nil if there are no servers.
+func (z *zone) NextServer() *proxy.Proxy {
+	z.RLock()
+	defer z.RUnlock()
+
+	healthy := make(map[string]int, len(z.order))
+	total, zones := 0, 0
+	for _, name := range z.order {
+		servers := z.zones[name].Servers()
+		if len(servers) == 0 {
+			continue
+		}
+		zones++
+		for _, s := range servers {
+			if s.IsAvailable() {
+				healthy[name]++
+				total++
+			}
+		}
+	}
+
+	if zones == 0 {
+		return nil
+	}
+
+	// Nothing is healthy, keep the traffic local if possible.
+	if total == 0 {
+		if local, ok := z.zones[z.local]; ok && len(local.Servers()) > 0 {
+			return pick(local)
+		}
+		for _, name := range z.order {
+			if len(z.zones[name].Servers()) > 0 {
+				return pick(z.zones[name])
+			}
+		}
+	}
+
+	// localPercent is the part of the traffic served by the local zone,
+	// the local share of healthy servers relative to an even share.
+	localPercent := float64(healthy[z.local]*zones) / float64(total)
+	if localPercent >= 1 {
+		return pick(z.zones[z.local])
+	}
+
+	// Deterministically keep localPercent of the requests in the local zone.
+	n := atomic.AddUint64(&z.next, 1) - 1
+	if localPercent > 0 && uint64(float64(n+1)*localPercent) > uint64(float64(n)*localPercent) {
+		return pick(z.zones[z.local])
+	}
+
+	// Spill over to the other zones, weighted by their healthy servers.
+	remote := total - healthy[z.local]
+	i := int(atomic.AddUint64(&z.spill, 1)-1) % remote
+	for _, name := range z.order {
+		if name == z.local {
+			continue
+		}
+		if i < healthy[name] {
+			return pick(z.zones[name])
+		}
+		i -= healthy[name]
+	}
+
+	return nil
+}
+
+// AddServers adds the servers to the zone of their locality.
+func (z *zone) AddServers(servers ...*proxy.Proxy) error {
+	if len(servers) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	z.Lock()
+	defer z.Unlock()
+
+	for _, s := range servers {
+		name := s.Locality().Zone
+		r, ok := z.zones[name]
+		if !ok {
+			r, _ = roundrobin.New()
+			z.zones[name] = r
+			z.order = append(z.order, name)
+		}
+		if err := r.AddServers(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveServers removes the servers from their zone and stops their health checks.
+func (z *zone) RemoveServers(names ...string) error {
+	if len(names) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	z.RLock()
+	defer z.RUnlock()
+
+	for _, r := range z.zones {
+		if err := r.RemoveServers(names...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DrainServers drains the servers of all zones concurrently, see roundrobin.RoundRobin.
+func (z *zone) DrainServers(ctx context.Context, names ...string) error {
+	if len(names) == 0 {
+		return roundrobin.ErrServersEmpty
+	}
+
+	z.RLock()
+	zones := make([]roundrobin.RoundRobin, 0, len(z.zones))
+	for _, r := range z.zones {
+		zones = append(zones, r)
+	}
+	z.RUnlock()
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(zones))
+	for i, r := range zones {
+		wg.Add(1)
+		go func(i int, r roundrobin.RoundRobin) {
+			defer wg.Done()
+			errs[i] = r.DrainServers(ctx, names...)
+		}(i, r)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Servers returns a list of all servers, grouped by zone.
+func (z *zone) Servers() []*proxy.Proxy {
+	z.RLock()
+	defer z.RUnlock()
+
+	var servers []*proxy.Proxy
+	for _, name := range z.order {
+		servers = append(servers, z.zones[name].Servers()...)
+	}
+	return servers
+}
+
+// RemoveAll removes all servers and stops their health checks.
+func (z *zone) RemoveAll() {
+	z.Lock()
+	defer z.Unlock()
+
+	for _, r := range z.zones {
+		r.RemoveAll()
+	}
+	z.zones = make(map[string]roundrobin.RoundRobin)
+	z.order = nil
+}
+
+// pick returns the next available server of the zone,
+// or the next server if none is available.
+func pick(r roundrobin.RoundRobin) *proxy.Proxy {
+	count := len(r.Servers())
+	for i := 0; i < count; i++ {
+		server := r.NextServer()
+		if server != nil && server.IsAvailable() {
+			return server
+		}
+	}
+	return r.NextServer()
+}
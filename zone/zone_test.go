@@ -0,0 +1,107 @@
+package zone
+
+import (
+	"context"
+	"testing"
+
+	"github.com/appleboy/loadbalancer-algorithms/internal/proxytest"
+	"github.com/appleboy/loadbalancer-algorithms/proxy"
+	"github.com/appleboy/loadbalancer-algorithms/proxy/health"
+)
+
+func newZoneProxy(t *testing.T, name, zone string, state health.State) *proxy.Proxy {
+	t.Helper()
+	return proxytest.NewProxy(t, name, state, proxy.WithLocality("us-east-1", zone))
+}
+
+func countZones(t *testing.T, servers []*proxy.Proxy, requests int) map[string]int {
+	t.Helper()
+	z, err := New("a", servers...)
+	if err != nil {
+		t.Fatalf("Failed to create zone balancer: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < requests; i++ {
+		s := z.NextServer()
+		if !s.IsAvailable() {
+			t.Fatalf("Expected an available server, but got %s", s.GetName())
+		}
+		counts[s.Locality().Zone]++
+	}
+	return counts
+}
+
+func TestNextServer(t *testing.T) {
+	healthy, unhealthy := health.StateHealthy, health.StateUnhealthy
+
+	t.Run("local zone provisioned", func(t *testing.T) {
+		counts := countZones(t, []*proxy.Proxy{
+			newZoneProxy(t, "a1", "a", healthy),
+			newZoneProxy(t, "a2", "a", healthy),
+			newZoneProxy(t, "b1", "b", healthy),
+			newZoneProxy(t, "b2", "b", healthy),
+		}, 100)
+		if counts["a"] != 100 {
+			t.Fatalf("Expected all traffic in the local zone, but got %v", counts)
+		}
+	})
+
+	t.Run("local zone under-provisioned", func(t *testing.T) {
+		counts := countZones(t, []*proxy.Proxy{
+			newZoneProxy(t, "a1", "a", healthy),
+			newZoneProxy(t, "a2", "a", unhealthy),
+			newZoneProxy(t, "b1", "b", healthy),
+			newZoneProxy(t, "b2", "b", healthy),
+			newZoneProxy(t, "b3", "b", healthy),
+		}, 100)
+		if counts["a"] != 50 || counts["b"] != 50 {
+			t.Fatalf("Expected half of the traffic to spill over, but got %v", counts)
+		}
+	})
+
+	t.Run("spill over is weighted by healthy servers", func(t *testing.T) {
+		counts := countZones(t, []*proxy.Proxy{
+			newZoneProxy(t, "a1", "a", unhealthy),
+			newZoneProxy(t, "b1", "b", healthy),
+			newZoneProxy(t, "c1", "c", healthy),
+			newZoneProxy(t, "c2", "c", healthy),
+			newZoneProxy(t, "c3", "c", healthy),
+		}, 100)
+		if counts["a"] != 0 || counts["b"] != 25 || counts["c"] != 75 {
+			t.Fatalf("Expected traffic weighted 1:3 between b and c, but got %v", counts)
+		}
+	})
+}
+
+func TestServers(t *testing.T) {
+	z, _ := New("a")
+	if z.NextServer() != nil {
+		t.Fatalf("Expected no server")
+	}
+
+	_ = z.AddServers(
+		newZoneProxy(t, "a1", "a", health.StateHealthy),
+		newZoneProxy(t, "b1", "b", health.StateHealthy),
+		newZoneProxy(t, "a2", "a", health.StateHealthy),
+	)
+	if got := len(z.Servers()); got != 3 {
+		t.Fatalf("Expected 3 servers, but got %d", got)
+	}
+
+	if err := z.RemoveServers("a1"); err != nil {
+		t.Fatalf("Failed to remove servers: %v", err)
+	}
+	if err := z.DrainServers(context.Background(), "b1"); err != nil {
+		t.Fatalf("Failed to drain servers: %v", err)
+	}
+	servers := z.Servers()
+	if len(servers) != 1 || servers[0].GetName() != "a2" {
+		t.Fatalf("Expected only a2, but got %d servers", len(servers))
+	}
+
+	z.RemoveAll()
+	if got := len(z.Servers()); got != 0 {
+		t.Fatalf("Expected 0 servers, but got %d", got)
+	}
+}